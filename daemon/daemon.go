@@ -3,6 +3,8 @@ package daemon
 import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/go-plugins-helpers/volume"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
 	"path/filepath"
 )
 
@@ -10,13 +12,30 @@ var (
 	defaultDir = filepath.Join(volume.DefaultDockerRootDirectory, "solidfire")
 )
 
-func Start(cfgFile string, debug bool) {
+func Start(cfgFile string, debug bool) error {
 	if debug == true {
 		log.SetLevel(log.DebugLevel)
 	} else {
 		log.SetLevel(log.InfoLevel)
 	}
-	d := New(cfgFile)
+	d, err := New(cfgFile)
+	if err != nil {
+		return err
+	}
+	if addr := d.Client.Config.MetricsAddress; addr != "" && d.Client.Metrics != nil {
+		go serveMetrics(addr, d)
+	}
 	h := volume.NewHandler(d)
-	log.Info(h.ServeUnix("root", "solidfire"))
+	return h.ServeUnix("root", "solidfire")
+}
+
+// serveMetrics serves the client's Prometheus metrics on /metrics.  Metrics
+// are best effort, so failing to serve them doesn't stop the daemon.
+func serveMetrics(addr string, d SolidFireDriver) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(d.Client.Metrics.Registry, promhttp.HandlerOpts{}))
+	log.Infof("Serving metrics on %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Errorf("Unable to serve metrics on %s: %v", addr, err)
+	}
 }
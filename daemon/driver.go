@@ -1,6 +1,9 @@
 package daemon
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/alecthomas/units"
 	"os"
@@ -8,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/go-plugins-helpers/volume"
 	"github.com/solidfire/solidfire-docker-driver/sfapi"
@@ -21,44 +25,105 @@ type SolidFireDriver struct {
 	InitiatorIFace string
 	Client         *sfapi.Client
 	Mutex          *sync.Mutex
+	Timeout        time.Duration
+	PurgeOnRemove  bool
 }
 
-func verifyConfiguration(cfg *sfapi.Config) {
+const defaultOperationTimeout = 2 * time.Minute
+
+// faultQueryTimeout bounds the lookup of cluster faults made after an
+// operation fails
+const faultQueryTimeout = 10 * time.Second
+
+// newContext returns the context used for the cluster calls made while
+// servicing a single Docker request, so a hung MVIP can't block Docker
+// indefinitely
+func (d SolidFireDriver) newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), d.Timeout)
+}
+
+// errorWithFaults returns the response for a failed operation on volumeID
+// (0 if there's no volume yet), adding any current cluster faults that may
+// explain the failure to the error
+func (d SolidFireDriver) errorWithFaults(volumeID int64, err error) volume.Response {
+	// The operation's own context may have expired, often why it failed
+	ctx, cancel := context.WithTimeout(context.Background(), faultQueryTimeout)
+	defer cancel()
+	msg := err.Error()
+	if faults := d.Client.FaultSummary(ctx, volumeID); faults != "" {
+		log.Error("Operation failed with active ", faults)
+		msg = fmt.Sprintf("%s (%s)", msg, faults)
+	}
+	return volume.Response{Err: msg}
+}
+
+func operationTimeout(cfg *sfapi.Config) time.Duration {
+	if cfg != nil && cfg.OperationTimeoutSecs > 0 {
+		return time.Duration(cfg.OperationTimeoutSecs) * time.Second
+	}
+	return defaultOperationTimeout
+}
+
+func verifyConfiguration(cfg *sfapi.Config) error {
 	// We want to verify we have everything we need to run the Docker driver
 	if cfg.TenantName == "" {
-		log.Fatal("TenantName required in SolidFire Docker config")
+		return errors.New("TenantName required in SolidFire Docker config")
 	}
-	if cfg.EndPoint == "" {
-		log.Fatal("EndPoint required in SolidFire Docker config")
+	if cfg.EndPoint == "" && len(cfg.EndPoints) == 0 {
+		return errors.New("EndPoint required in SolidFire Docker config")
 	}
 	if cfg.DefaultVolSz == 0 {
-		log.Fatal("DefaultVolSz required in SolidFire Docker config")
+		return errors.New("DefaultVolSz required in SolidFire Docker config")
 	}
 	if cfg.SVIP == "" {
-		log.Fatal("SVIP required in SolidFire Docker config")
+		return errors.New("SVIP required in SolidFire Docker config")
 	}
+	return nil
 }
-func New(cfgFile string) SolidFireDriver {
-	var tenantID int64
-	client, _ := sfapi.NewFromConfig(cfgFile)
 
-	req := sfapi.GetAccountByNameRequest{
-		Name: client.DefaultTenantName,
+// getOrCreateTenant returns the ID of the account volumes are created in,
+// creating the account only if the cluster reports it doesn't exist
+func getOrCreateTenant(ctx context.Context, client *sfapi.Client, name string) (int64, error) {
+	account, err := client.GetAccountByName(ctx, &sfapi.GetAccountByNameRequest{Name: name})
+	if err == nil {
+		log.Debug("Set tenantID: ", account.AccountID)
+		return account.AccountID, nil
+	}
+	if !sfapi.IsNotFound(err) {
+		log.Errorf("Failed init, unable to look up Tenant (%s): %v", name, err)
+		return 0, fmt.Errorf("unable to look up tenant %s: %w", name, err)
+	}
+	tenantID, err := client.AddAccount(ctx, &sfapi.AddAccountRequest{Username: name})
+	if err != nil {
+		log.Errorf("Failed init, unable to create Tenant (%s): %v", name, err)
+		return 0, fmt.Errorf("unable to create tenant %s: %w", name, err)
 	}
-	account, err := client.GetAccountByName(&req)
+	log.Debug("Set tenantID: ", tenantID)
+	return tenantID, nil
+}
+
+func New(cfgFile string) (SolidFireDriver, error) {
+	conf, err := sfapi.ProcessConfig(cfgFile)
 	if err != nil {
-		req := sfapi.AddAccountRequest{
-			Username: client.DefaultTenantName,
-		}
-		actID, err := client.AddAccount(&req)
-		if err != nil {
-			log.Fatalf("Failed init, unable to create Tenant (%s): %+v", client.DefaultTenantName, err)
-		}
-		tenantID = actID
-		log.Debug("Set tenantID: ", tenantID)
-	} else {
-		tenantID = account.AccountID
-		log.Debug("Set tenantID: ", tenantID)
+		return SolidFireDriver{}, fmt.Errorf("failed to initialize SolidFire client from %s: %w", cfgFile, err)
+	}
+	if err := verifyConfiguration(&conf); err != nil {
+		return SolidFireDriver{}, fmt.Errorf("invalid config file %s: %w", cfgFile, err)
+	}
+	client, err := sfapi.New(conf)
+	if err != nil {
+		return SolidFireDriver{}, fmt.Errorf("failed to initialize SolidFire client from %s: %w", cfgFile, err)
+	}
+	timeout := operationTimeout(client.Config)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := client.NegotiateAPIVersion(ctx); err != nil {
+		return SolidFireDriver{}, err
+	}
+
+	tenantID, err := getOrCreateTenant(ctx, client, client.DefaultTenantName)
+	if err != nil {
+		return SolidFireDriver{}, err
 	}
 	baseMountPoint := "/var/lib/solidfire/mount"
 	if client.Config.MountPoint != "" {
@@ -73,7 +138,8 @@ func New(cfgFile string) SolidFireDriver {
 	_, err = os.Lstat(baseMountPoint)
 	if os.IsNotExist(err) {
 		if err := os.MkdirAll(baseMountPoint, 0755); err != nil {
-			log.Fatal("Failed to create Mount directory during driver init: %v", err)
+			log.Errorf("Failed to create Mount directory during driver init: %v", err)
+			return SolidFireDriver{}, fmt.Errorf("unable to create mount directory %s: %w", baseMountPoint, err)
 		}
 	}
 
@@ -84,29 +150,26 @@ func New(cfgFile string) SolidFireDriver {
 		DefaultVolSz:   client.DefaultVolSize,
 		MountPoint:     client.Config.MountPoint,
 		InitiatorIFace: iscsiInterface,
+		Timeout:        timeout,
+		PurgeOnRemove:  client.Config.PurgeOnRemove,
 	}
-	return d
+	return d, nil
 }
 
-func NewSolidFireDriverFromConfig(c *sfapi.Config) SolidFireDriver {
-	var tenantID int64
-
-	client, _ := sfapi.NewFromConfig("")
-	req := sfapi.GetAccountByNameRequest{
-		Name: c.TenantName,
+func NewSolidFireDriverFromConfig(c *sfapi.Config) (SolidFireDriver, error) {
+	client, err := sfapi.New(*c)
+	if err != nil {
+		return SolidFireDriver{}, fmt.Errorf("failed to initialize SolidFire client: %w", err)
 	}
-
-	account, err := client.GetAccountByName(&req)
+	timeout := operationTimeout(c)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := client.NegotiateAPIVersion(ctx); err != nil {
+		return SolidFireDriver{}, err
+	}
+	tenantID, err := getOrCreateTenant(ctx, client, c.TenantName)
 	if err != nil {
-		req := sfapi.AddAccountRequest{
-			Username: c.TenantName,
-		}
-		tenantID, err = client.AddAccount(&req)
-		if err != nil {
-			log.Fatal("Failed to initialize solidfire driver while creating tenant: ", err)
-		}
-	} else {
-		tenantID = account.AccountID
+		return SolidFireDriver{}, err
 	}
 
 	baseMountPoint := "/var/lib/solidfire/mount"
@@ -131,7 +194,8 @@ func NewSolidFireDriverFromConfig(c *sfapi.Config) SolidFireDriver {
 	_, err = os.Lstat(baseMountPoint)
 	if os.IsNotExist(err) {
 		if err := os.MkdirAll(baseMountPoint, 0755); err != nil {
-			log.Fatal("Failed to create Mount directory during driver init: %v", err)
+			log.Errorf("Failed to create Mount directory during driver init: %v", err)
+			return SolidFireDriver{}, fmt.Errorf("unable to create mount directory %s: %w", baseMountPoint, err)
 		}
 	}
 
@@ -142,10 +206,12 @@ func NewSolidFireDriverFromConfig(c *sfapi.Config) SolidFireDriver {
 		DefaultVolSz:   defaultVolSize,
 		MountPoint:     c.MountPoint,
 		InitiatorIFace: iscsiInterface,
+		Timeout:        timeout,
+		PurgeOnRemove:  c.PurgeOnRemove,
 	}
 	log.Debugf("Driver initialized with the following settings:\n%+v\n", d)
 	log.Info("Succesfuly initialized SolidFire Docker driver")
-	return d
+	return d, nil
 }
 
 func formatOpts(r volume.Request) {
@@ -160,6 +226,8 @@ func formatOpts(r volume.Request) {
 			r.Options["type"] = v
 		} else if strings.EqualFold(k, "qos") {
 			r.Options["qos"] = v
+		} else if strings.EqualFold(k, "schedule") {
+			r.Options["schedule"] = v
 		}
 	}
 }
@@ -168,13 +236,15 @@ func (d SolidFireDriver) Create(r volume.Request) volume.Response {
 	log.Infof("Create volume %s on %s\n", r.Name, "solidfire")
 	d.Mutex.Lock()
 	defer d.Mutex.Unlock()
+	ctx, cancel := d.newContext()
+	defer cancel()
 	var req sfapi.CreateVolumeRequest
 	var qos sfapi.QoS
 	var vsz int64
 
 	log.Debugf("GetVolumeByName: %s, %d", r.Name, d.TenantID)
 	log.Debugf("Options passed in to create: %+v", r.Options)
-	v, err := d.Client.GetVolumeByName(r.Name, d.TenantID)
+	v, err := d.Client.GetVolumeByName(ctx, r.Name, d.TenantID)
 	if err == nil && v.VolumeID != 0 {
 		log.Infof("Found existing Volume by Name: %s", r.Name)
 		return volume.Response{}
@@ -199,42 +269,95 @@ func (d SolidFireDriver) Create(r volume.Request) volume.Response {
 		qos.MinIOPS, _ = strconv.ParseInt(iops[0], 10, 64)
 		qos.MaxIOPS, _ = strconv.ParseInt(iops[1], 10, 64)
 		qos.BurstIOPS, _ = strconv.ParseInt(iops[2], 10, 64)
-		req.Qos = qos
-		log.Infof("Received qos r.Options in Create: %+v", req.Qos)
+		req.Qos = &qos
+		log.Infof("Received qos r.Options in Create: %+v", qos)
 	}
 
-	if r.Options["type"] != "" {
+	if r.Options["type"] != "" && d.Client.VolumeTypes != nil {
 		for _, t := range *d.Client.VolumeTypes {
 			if strings.EqualFold(t.Type, r.Options["type"]) {
-				req.Qos = t.QOS
-				log.Infof("Received Type r.Options in Create and set QoS: %+v", req.Qos)
+				req.Qos, req.QoSPolicyID, err = d.Client.VolTypeQoS(ctx, t)
+				if err != nil {
+					log.Error("Failed to determine QoS for type ", t.Type, ": ", err)
+					return volume.Response{Err: err.Error()}
+				}
+				log.Infof("Received Type r.Options in Create and set QoS: %+v (policy %d)", req.Qos, req.QoSPolicyID)
 				break
 			}
 		}
 	}
 
+	// Look the schedule up first so a typo doesn't leave behind a volume
+	// that isn't being snapshotted
+	var schedule sfapi.Schedule
+	if r.Options["schedule"] != "" {
+		schedule, err = d.Client.GetScheduleByName(ctx, r.Options["schedule"])
+		if err != nil {
+			log.Error("Failed to find schedule ", r.Options["schedule"], ": ", err)
+			return volume.Response{Err: err.Error()}
+		}
+	}
+
+	// Refuse up front rather than fail with an obscure error from the
+	// cluster, but don't let a failed capacity query block creates
+	if err := d.Client.CheckCapacity(ctx, vsz); err != nil {
+		if errors.Is(err, sfapi.ErrClusterFull) {
+			return volume.Response{Err: fmt.Sprintf("unable to create volume %s: %v", r.Name, err)}
+		}
+		log.Warning("Unable to check cluster capacity, creating anyway: ", err)
+	}
+
 	req.TotalSize = vsz
 	req.AccountID = d.TenantID
 	req.Name = r.Name
-	_, err = d.Client.CreateVolume(&req)
+	v, err = d.Client.CreateVolume(ctx, &req)
 	if err != nil {
-		return volume.Response{Err: err.Error()}
+		return d.errorWithFaults(0, err)
+	}
+	if schedule.ScheduleID != 0 {
+		if _, err := d.Client.AddVolumesToSchedule(ctx, schedule.ScheduleID, []int64{v.VolumeID}); err != nil {
+			log.Errorf("Failed to add volume %s to schedule %s: %v", r.Name, schedule.Name, err)
+			// Remove the new volume, otherwise Docker's retry finds it by
+			// name and reports success without the schedule
+			if derr := d.Client.DeleteVolume(ctx, v.VolumeID); derr != nil {
+				log.Errorf("Failed to delete volume %s after adding it to schedule %s failed: %v", r.Name, schedule.Name, derr)
+			}
+			return volume.Response{Err: fmt.Sprintf("unable to add volume %s to schedule %s: %v", r.Name, schedule.Name, err)}
+		}
+		log.Infof("Added volume %s to schedule %s", r.Name, schedule.Name)
 	}
 	return volume.Response{}
 }
 
 func (d SolidFireDriver) Remove(r volume.Request) volume.Response {
 	log.Info("Remove/Delete Volume: ", r.Name)
-	v, err := d.Client.GetVolumeByName(r.Name, d.TenantID)
+	ctx, cancel := d.newContext()
+	defer cancel()
+	v, err := d.Client.GetVolumeByName(ctx, r.Name, d.TenantID)
 	if err != nil {
 		log.Error("Failed to retrieve volume named ", r.Name, "during Remove operation: ", err)
 		return volume.Response{Err: err.Error()}
 	}
-	d.Client.DetachVolume(v)
-	err = d.Client.DeleteVolume(v.VolumeID)
+	d.Client.DetachVolume(ctx, v)
+	err = d.Client.DeleteVolume(ctx, v.VolumeID)
 	if err != nil {
-		// FIXME(jdg): Check if it's a "DNE" error in that case we're golden
+		if sfapi.IsNotFound(err) {
+			// Already gone on the cluster, nothing left for us to do
+			log.Info("Volume ", r.Name, " no longer exists on the cluster: ", err)
+			return volume.Response{}
+		}
 		log.Error("Error encountered during delete: ", err)
+		return volume.Response{Err: err.Error()}
+	}
+	if d.PurgeOnRemove {
+		// The volume is already gone as far as Docker is concerned, and the
+		// cluster purges it itself once the window expires, so a failure
+		// here isn't worth failing the remove over
+		if err := d.Client.PurgeDeletedVolume(ctx, v.VolumeID); err != nil {
+			log.Error("Failed to purge deleted volume ", r.Name, ": ", err)
+		}
+	} else {
+		log.Infof("Volume %s (ID %d) can be restored until it's purged by the cluster", r.Name, v.VolumeID)
 	}
 	return volume.Response{}
 }
@@ -250,21 +373,26 @@ func (d SolidFireDriver) Mount(r volume.Request) volume.Response {
 	d.Mutex.Lock()
 	defer d.Mutex.Unlock()
 	log.Infof("Mounting volume %s on %s\n", r.Name, "solidfire")
-	v, err := d.Client.GetVolumeByName(r.Name, d.TenantID)
+	ctx, cancel := d.newContext()
+	defer cancel()
+	v, err := d.Client.GetVolumeByName(ctx, r.Name, d.TenantID)
 	if err != nil {
-		log.Errorf("Failed to retrieve volume by name in mount operation: ", r.Name)
-		return volume.Response{Err: err.Error()}
-	}
-	path, device, err := d.Client.AttachVolume(&v, d.InitiatorIFace)
-	if path == "" || device == "" && err == nil {
-		log.Error("Missing path or device, but err not set?")
-		log.Debug("Path: ", path, ",Device: ", device)
-		return volume.Response{Err: err.Error()}
-
+		log.Errorf("Failed to retrieve volume by name in mount operation: %s", r.Name)
+		if sfapi.IsNotFound(err) {
+			return volume.Response{Err: err.Error()}
+		}
+		return d.errorWithFaults(0, err)
 	}
+	path, device, err := d.Client.AttachVolume(ctx, &v, d.InitiatorIFace)
 	if err != nil {
 		log.Errorf("Failed to perform iscsi attach of volume %s: %v", r.Name, err)
-		return volume.Response{Err: err.Error()}
+		return d.errorWithFaults(v.VolumeID, err)
+	}
+	if path == "" || device == "" {
+		log.Error("Missing path or device, but err not set?")
+		log.Debug("Path: ", path, ",Device: ", device)
+		err = fmt.Errorf("iSCSI attach of volume %s didn't produce a device", r.Name)
+		return d.errorWithFaults(v.VolumeID, err)
 	}
 	log.Debugf("Attached volume at (path, devfile): %s, %s", path, device)
 	if sfapi.GetFSType(device) == "" {
@@ -275,7 +403,7 @@ func (d SolidFireDriver) Mount(r volume.Request) volume.Response {
 			return volume.Response{Err: err.Error()}
 		}
 	}
-	if sfapi.Mount(device, d.MountPoint+"/"+r.Name) != nil {
+	if err := sfapi.Mount(device, d.MountPoint+"/"+r.Name); err != nil {
 		log.Error("Failed to mount volume: ", r.Name)
 		return volume.Response{Err: err.Error()}
 	}
@@ -285,18 +413,22 @@ func (d SolidFireDriver) Mount(r volume.Request) volume.Response {
 func (d SolidFireDriver) Unmount(r volume.Request) volume.Response {
 	log.Info("Unmounting volume: ", r.Name)
 	sfapi.Umount(filepath.Join(d.MountPoint, r.Name))
-	v, err := d.Client.GetVolumeByName(r.Name, d.TenantID)
+	ctx, cancel := d.newContext()
+	defer cancel()
+	v, err := d.Client.GetVolumeByName(ctx, r.Name, d.TenantID)
 	if err != nil {
 		return volume.Response{Err: err.Error()}
 	}
-	d.Client.DetachVolume(v)
+	d.Client.DetachVolume(ctx, v)
 	return volume.Response{}
 }
 
 func (d SolidFireDriver) Get(r volume.Request) volume.Response {
 	log.Info("Get volume: ", r.Name)
+	ctx, cancel := d.newContext()
+	defer cancel()
 	path := filepath.Join(d.MountPoint, r.Name)
-	v, err := d.Client.GetVolumeByName(r.Name, d.TenantID)
+	v, err := d.Client.GetVolumeByName(ctx, r.Name, d.TenantID)
 	if err != nil {
 		log.Error("Failed to retrieve volume named ", r.Name, "during Get operation: ", err)
 		return volume.Response{Err: err.Error()}
@@ -307,19 +439,19 @@ func (d SolidFireDriver) Get(r volume.Request) volume.Response {
 func (d SolidFireDriver) List(r volume.Request) volume.Response {
 	log.Info("Get volume: ", r.Name)
 	path := filepath.Join(d.MountPoint, r.Name)
+	ctx, cancel := d.newContext()
+	defer cancel()
 	var vols []*volume.Volume
-	var req sfapi.ListVolumesForAccountRequest
-	req.AccountID = d.TenantID
-	vlist, err := d.Client.ListVolumesForAccount(&req)
-	if err != nil {
-		log.Error("Failed to retrieve volume list:", err)
-		return volume.Response{Err: err.Error()}
-	}
-
-	for _, v := range vlist {
+	it := d.Client.ListVolumesForAccountIter(ctx, d.TenantID, 0)
+	for it.Next() {
+		v := it.Volume()
 		if v.Status == "active" && v.AccountID == d.TenantID {
 			vols = append(vols, &volume.Volume{Name: v.Name, Mountpoint: path})
 		}
 	}
+	if err := it.Err(); err != nil {
+		log.Error("Failed to retrieve volume list:", err)
+		return volume.Response{Err: err.Error()}
+	}
 	return volume.Response{Volumes: vols}
 }
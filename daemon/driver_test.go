@@ -0,0 +1,262 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/units"
+	"github.com/docker/go-plugins-helpers/volume"
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+	"github.com/solidfire/solidfire-docker-driver/sfapi/fake"
+)
+
+// newFakeDriver starts a fake cluster and returns it along with a driver
+// using it
+func newFakeDriver(t *testing.T) (*fake.Cluster, SolidFireDriver) {
+	t.Helper()
+	fc := fake.NewCluster()
+	t.Cleanup(fc.Close)
+	cfg := fc.Config()
+	cfg.MountPoint = t.TempDir()
+	d, err := NewSolidFireDriverFromConfig(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fc, d
+}
+
+func TestNewDriverCreatesTenant(t *testing.T) {
+	fc, d := newFakeDriver(t)
+	accounts := fc.Accounts()
+	if len(accounts) != 1 || accounts[0].Username != "docker" || accounts[0].AccountID != d.TenantID {
+		t.Fatalf("expected the driver to create the docker tenant, got %+v", accounts)
+	}
+
+	// A second driver reuses the existing tenant
+	cfg := fc.Config()
+	cfg.MountPoint = d.MountPoint
+	d2, err := NewSolidFireDriverFromConfig(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d2.TenantID != d.TenantID || len(fc.Accounts()) != 1 {
+		t.Fatalf("expected tenant %d to be reused, got %d", d.TenantID, d2.TenantID)
+	}
+}
+
+func TestNewDriverLookupFailure(t *testing.T) {
+	fc := fake.NewCluster()
+	t.Cleanup(fc.Close)
+	fc.InjectFault(fake.Fault{Method: "GetAccountByName", Err: &sfapi.APIError{Code: 500, Name: "xPermissionDenied", Message: "denied"}})
+	cfg := fc.Config()
+	cfg.MountPoint = t.TempDir()
+	if _, err := NewSolidFireDriverFromConfig(&cfg); err == nil || !strings.Contains(err.Error(), "unable to look up tenant") {
+		t.Fatalf("expected the failed lookup to be reported, got %v", err)
+	}
+	if n := len(fc.Accounts()); n != 0 {
+		t.Fatalf("expected no tenant to be created when the lookup failed, got %d accounts", n)
+	}
+}
+
+func TestNewFromConfigFile(t *testing.T) {
+	fc := fake.NewCluster()
+	t.Cleanup(fc.Close)
+	write := func(cfg sfapi.Config) string {
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "solidfire.json")
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for field, clear := range map[string]func(*sfapi.Config){
+		"TenantName":   func(c *sfapi.Config) { c.TenantName = "" },
+		"EndPoint":     func(c *sfapi.Config) { c.EndPoint = "" },
+		"DefaultVolSz": func(c *sfapi.Config) { c.DefaultVolSz = 0 },
+		"SVIP":         func(c *sfapi.Config) { c.SVIP = "" },
+	} {
+		cfg := fc.Config()
+		clear(&cfg)
+		if _, err := New(write(cfg)); err == nil || !strings.Contains(err.Error(), field+" required") {
+			t.Errorf("expected a config without %s to be refused, got %v", field, err)
+		}
+	}
+	if n := len(fc.Accounts()); n != 0 {
+		t.Fatalf("expected no requests for an incomplete config, got %d accounts", n)
+	}
+
+	cfg := fc.Config()
+	cfg.MountPoint = t.TempDir()
+	d, err := New(write(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.MountPoint != cfg.MountPoint || d.TenantID == 0 {
+		t.Fatalf("driver not configured from the file: %+v", d)
+	}
+}
+
+func TestCreateGetListRemove(t *testing.T) {
+	fc, d := newFakeDriver(t)
+	if r := d.Create(volume.Request{Name: "data", Options: map[string]string{"Size": "2", "qos": "100,1000,2000"}}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+	if r := d.Create(volume.Request{Name: "logs"}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+	vols := fc.Volumes()
+	if len(vols) != 2 {
+		t.Fatalf("expected 2 volumes, got %d", len(vols))
+	}
+	if vols[0].TotalSize != 2*int64(units.GiB) || vols[0].Qos.MaxIOPS != 1000 {
+		t.Fatalf("size and qos options not applied: %+v", vols[0])
+	}
+
+	// Creating an existing volume succeeds without creating another
+	if r := d.Create(volume.Request{Name: "data"}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+	if n := len(fc.Volumes()); n != 2 {
+		t.Fatalf("expected 2 volumes after re-creating, got %d", n)
+	}
+
+	r := d.Get(volume.Request{Name: "data"})
+	if r.Err != "" || r.Volume == nil || r.Volume.Name != "data" {
+		t.Fatalf("Get: %+v", r)
+	}
+	if r := d.Get(volume.Request{Name: "missing"}); r.Err == "" {
+		t.Fatal("expected Get of a missing volume to fail")
+	}
+	r = d.List(volume.Request{})
+	if r.Err != "" || len(r.Volumes) != 2 {
+		t.Fatalf("List: %+v", r)
+	}
+
+	if r := d.Remove(volume.Request{Name: "logs"}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+	r = d.List(volume.Request{})
+	if r.Err != "" || len(r.Volumes) != 1 || r.Volumes[0].Name != "data" {
+		t.Fatalf("List after remove: %+v", r)
+	}
+	if r := d.Remove(volume.Request{Name: "logs"}); r.Err == "" {
+		t.Fatal("expected removing a removed volume to fail")
+	}
+}
+
+func TestRemovePurges(t *testing.T) {
+	fc, d := newFakeDriver(t)
+	d.PurgeOnRemove = true
+	if r := d.Create(volume.Request{Name: "data"}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+	if r := d.Remove(volume.Request{Name: "data"}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+	if n := len(fc.Volumes()); n != 0 {
+		t.Fatalf("expected the volume to be purged, %d left", n)
+	}
+}
+
+func TestCreateRefusedWhenFull(t *testing.T) {
+	fc, d := newFakeDriver(t)
+	fc.BlockFullness = "stage4Critical"
+	r := d.Create(volume.Request{Name: "full"})
+	if !strings.Contains(r.Err, "cluster is full") || !strings.Contains(r.Err, "stage4Critical") {
+		t.Fatalf("expected a cluster full error, got %q", r.Err)
+	}
+	fc.BlockFullness = "stage3Low"
+	fc.MaxProvisionedBytes = int64(units.GiB)
+	r = d.Create(volume.Request{Name: "big", Options: map[string]string{"size": "5"}})
+	if !strings.Contains(r.Err, "maximum provisioned space") {
+		t.Fatalf("expected a provisioned space error, got %q", r.Err)
+	}
+	if n := len(fc.Volumes()); n != 0 {
+		t.Fatalf("expected no volumes to be created, got %d", n)
+	}
+
+	// A failed capacity check doesn't block the create
+	fc.MaxProvisionedBytes = 50 * int64(units.TiB)
+	fc.InjectFault(fake.Fault{Method: "GetClusterFullThreshold", Err: &sfapi.APIError{Code: 500, Name: "xUnknown", Message: "boom"}})
+	if r := d.Create(volume.Request{Name: "unknown"}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+}
+
+func TestCreateErrorIncludesFaults(t *testing.T) {
+	fc, d := newFakeDriver(t)
+	fc.AddClusterFault(sfapi.ClusterFault{Code: "driveFailed", Severity: "error", Type: "drive", Details: "drive 4 failed"})
+	fc.AddClusterFault(sfapi.ClusterFault{Code: "ntpMissing", Severity: "bestPractice", Type: "cluster", Details: "no ntp"})
+	resolved := fc.AddClusterFault(sfapi.ClusterFault{Code: "nodeOffline", Severity: "critical", Type: "node", Details: "node 2 offline"})
+	fc.ResolveClusterFault(resolved)
+	fc.AddClusterFault(sfapi.ClusterFault{Code: "volumeDegraded", Severity: "warning", Type: "service",
+		Details: "volume 99 degraded", Data: map[string]interface{}{"volumeID": 99}})
+
+	fc.InjectFault(fake.Fault{Method: "CreateVolume", Err: &sfapi.APIError{Code: 500, Name: "xUnknown", Message: "boom"}})
+	r := d.Create(volume.Request{Name: "data"})
+	if !strings.Contains(r.Err, "boom") || !strings.Contains(r.Err, "cluster faults: error driveFailed: drive 4 failed") {
+		t.Fatalf("expected the create error to include the drive fault, got %q", r.Err)
+	}
+	for _, code := range []string{"ntpMissing", "nodeOffline", "volumeDegraded"} {
+		if strings.Contains(r.Err, code) {
+			t.Fatalf("expected %s to be left out of %q", code, r.Err)
+		}
+	}
+}
+
+func TestListError(t *testing.T) {
+	fc, d := newFakeDriver(t)
+	fc.InjectFault(fake.Fault{Method: "ListVolumesForAccount", Err: &sfapi.APIError{Code: 500, Name: "xUnknown", Message: "boom"}})
+	if r := d.List(volume.Request{}); !strings.Contains(r.Err, "boom") {
+		t.Fatalf("expected the list error, got %q", r.Err)
+	}
+}
+
+func TestCreateWithSchedule(t *testing.T) {
+	fc, d := newFakeDriver(t)
+	s := sfapi.NewSnapshotSchedule("nightly", nil, 0)
+	s.SetDaily(2, 0)
+	if _, err := d.Client.CreateSchedule(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if r := d.Create(volume.Request{Name: "typo", Options: map[string]string{"schedule": "nightlly"}}); r.Err == "" {
+		t.Fatal("expected an unknown schedule to fail the create")
+	}
+	if n := len(fc.Volumes()); n != 0 {
+		t.Fatalf("expected no volume to be created for an unknown schedule, got %d", n)
+	}
+
+	// A volume that couldn't be added to its schedule is removed, so
+	// Docker's retry creates it again rather than finding it by name
+	fc.InjectFault(fake.Fault{Method: "ModifySchedule", Err: &sfapi.APIError{Code: 500, Name: "xUnknown", Message: "boom"}, Count: 1})
+	r := d.Create(volume.Request{Name: "data", Options: map[string]string{"schedule": "nightly"}})
+	if !strings.Contains(r.Err, "schedule nightly") || !strings.Contains(r.Err, "boom") {
+		t.Fatalf("expected the schedule error, got %q", r.Err)
+	}
+	if vols := fc.Volumes(); len(vols) != 1 || vols[0].Status != "deleted" {
+		t.Fatalf("expected the volume to be deleted, got %+v", vols)
+	}
+	if r := d.Create(volume.Request{Name: "data", Options: map[string]string{"schedule": "nightly"}}); r.Err != "" {
+		t.Fatal(r.Err)
+	}
+	v, err := d.Client.GetVolumeByName(context.Background(), "data", d.TenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedules, err := d.Client.ListSchedules(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 || len(schedules[0].ScheduleInfo.VolumeIDs) != 1 ||
+		schedules[0].ScheduleInfo.VolumeIDs[0] != v.VolumeID {
+		t.Fatalf("expected the retried volume %d on the schedule, got %+v", v.VolumeID, schedules)
+	}
+}
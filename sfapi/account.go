@@ -1,41 +1,122 @@
 package sfapi
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
+	"strings"
 )
 
-func (c *Client) AddAccount(req *AddAccountRequest) (accountID int64, err error) {
+func (c *Client) AddAccount(ctx context.Context, req *AddAccountRequest) (accountID int64, err error) {
 	var result AddAccountResult
-	response, err := c.Request("AddAccount", req, newReqID())
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	var existing int64
+	applied := func() bool {
+		a, err := c.GetAccountByName(ctx, &GetAccountByNameRequest{Name: req.Username})
+		if err != nil {
+			return false
+		}
+		existing = a.AccountID
+		return true
+	}
+	response, done, err := c.requestWithRetry(ctx, "AddAccount", req, newReqID(), applied)
+	if err != nil {
+		return 0, err
+	}
+	if done {
+		return existing, nil
+	}
+	if err := decodeResponse("AddAccount", response, &result); err != nil {
 		return 0, err
 	}
 	return result.Result.AccountID, nil
 }
 
-func (c *Client) GetAccountByName(req *GetAccountByNameRequest) (account Account, err error) {
-	response, err := c.Request("GetAccountByName", req, newReqID())
+func (c *Client) GetAccountByName(ctx context.Context, req *GetAccountByNameRequest) (account Account, err error) {
+	response, err := c.Request(ctx, "GetAccountByName", req, newReqID())
 	if err != nil {
 		return
 	}
 
 	var result GetAccountResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	if err := decodeResponse("GetAccountByName", response, &result); err != nil {
 		return Account{}, err
 	}
 	log.Debugf("Returning account: %v", result.Result.Account)
 	return result.Result.Account, err
 }
 
-func (c *Client) GetAccountByID(req *GetAccountByIDRequest) (account Account, err error) {
+func (c *Client) GetAccountByID(ctx context.Context, req *GetAccountByIDRequest) (account Account, err error) {
 	var result GetAccountResult
-	response, err := c.Request("GetAccountByID", req, newReqID())
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	response, err := c.Request(ctx, "GetAccountByID", req, newReqID())
+	if err != nil {
+		return account, err
+	}
+	if err := decodeResponse("GetAccountByID", response, &result); err != nil {
 		return account, err
 	}
 	return result.Result.Account, err
 }
+
+func (c *Client) ListAccounts(ctx context.Context) (accounts []Account, err error) {
+	var req ListAccountsRequest
+	response, err := c.Request(ctx, "ListAccounts", &req, newReqID())
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	var result ListAccountsResult
+	if err := decodeResponse("ListAccounts", response, &result); err != nil {
+		return nil, err
+	}
+	return result.Result.Accounts, nil
+}
+
+// AccountStatuses are the valid values of an account's status
+var AccountStatuses = []string{"active", "locked"}
+
+// ModifyAccount changes the status, CHAP secrets or attributes of an
+// account, only the fields set in the request are changed.  Locking an
+// account drops the iSCSI sessions of all of its volumes.
+func (c *Client) ModifyAccount(ctx context.Context, req *ModifyAccountRequest) (account Account, err error) {
+	if req.Status != "" && req.Status != "active" && req.Status != "locked" {
+		return Account{}, fmt.Errorf("invalid account status %q, expected one of %s", req.Status, strings.Join(AccountStatuses, ", "))
+	}
+	_, err = c.Request(ctx, "ModifyAccount", req, newReqID())
+	if err != nil {
+		log.Errorf("Failed to modify account %d: %v", req.AccountID, err)
+		return Account{}, err
+	}
+	return c.GetAccountByID(ctx, &GetAccountByIDRequest{AccountID: req.AccountID})
+}
+
+// RemoveAccount deletes an account, the cluster refuses to remove accounts
+// that still own volumes, including deleted volumes that haven't been
+// purged yet
+func (c *Client) RemoveAccount(ctx context.Context, accountID int64) (err error) {
+	applied := func() bool {
+		_, err := c.GetAccountByID(ctx, &GetAccountByIDRequest{AccountID: accountID})
+		return IsNotFound(err)
+	}
+	req := RemoveAccountRequest{AccountID: accountID}
+	_, _, err = c.requestWithRetry(ctx, "RemoveAccount", &req, newReqID(), applied)
+	if err != nil {
+		log.Error("Failed to remove account ID: ", accountID)
+		return err
+	}
+	return nil
+}
+
+// GetAccountEfficiency returns the compression, deduplication and thin
+// provisioning ratios of an account's volumes
+func (c *Client) GetAccountEfficiency(ctx context.Context, accountID int64) (efficiency AccountEfficiency, err error) {
+	req := GetAccountEfficiencyRequest{AccountID: accountID}
+	response, err := c.Request(ctx, "GetAccountEfficiency", &req, newReqID())
+	if err != nil {
+		return efficiency, err
+	}
+	var result GetAccountEfficiencyResult
+	if err := decodeResponse("GetAccountEfficiency", response, &result); err != nil {
+		return efficiency, err
+	}
+	return result.Result, nil
+}
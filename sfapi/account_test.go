@@ -0,0 +1,105 @@
+package sfapi_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+func getAccount(t *testing.T, c *sfapi.Client, accountID int64) sfapi.Account {
+	t.Helper()
+	a, err := c.GetAccountByID(context.Background(), &sfapi.GetAccountByIDRequest{AccountID: accountID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestModifyAccountStatus(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		status, want, err string
+	}{
+		{"locked", "locked", ""},
+		{"", "locked", ""},
+		{"active", "active", ""},
+		{"disabled", "active", "invalid account status"},
+		{"Locked", "active", "invalid account status"},
+	} {
+		calls := fc.Calls("ModifyAccount")
+		a, err := c.ModifyAccount(ctx, &sfapi.ModifyAccountRequest{AccountID: accountID, Status: tc.status})
+		if tc.err == "" && (err != nil || a.Status != tc.want) {
+			t.Errorf("status %q: expected %s, got %+v, %v", tc.status, tc.want, a, err)
+		}
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("status %q: expected an error containing %q, got %v", tc.status, tc.err, err)
+			}
+			// Rejected before it's sent to the cluster
+			if fc.Calls("ModifyAccount") != calls {
+				t.Errorf("status %q: expected no ModifyAccount request", tc.status)
+			}
+		}
+		if s := getAccount(t, c, accountID).Status; s != tc.want {
+			t.Errorf("status %q: expected the account to be %s, got %s", tc.status, tc.want, s)
+		}
+	}
+}
+
+func TestRemoveAccountWithVolumes(t *testing.T) {
+	_, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	v := createVolume(t, c, accountID, "data")
+
+	if err := c.RemoveAccount(ctx, accountID); sfapi.ErrorName(err) != "xAccountHasVolumes" {
+		t.Fatalf("expected an account that owns a volume not to be removed, got %v", err)
+	}
+	// Deleted volumes still count until they're purged
+	if err := c.DeleteVolume(ctx, v.VolumeID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveAccount(ctx, accountID); sfapi.ErrorName(err) != "xAccountHasVolumes" {
+		t.Fatalf("expected an account that owns a deleted volume not to be removed, got %v", err)
+	}
+	if err := c.PurgeDeletedVolume(ctx, v.VolumeID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveAccount(ctx, accountID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetAccountByID(ctx, &sfapi.GetAccountByIDRequest{AccountID: accountID}); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected the account to be gone, got %v", err)
+	}
+	if err := c.RemoveAccount(ctx, accountID); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected removing it again to fail with not found, got %v", err)
+	}
+}
+
+func TestRemoveAccountAfterMovingVolumes(t *testing.T) {
+	_, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	v := createVolume(t, c, accountID, "data")
+	otherID, err := c.AddAccount(ctx, &sfapi.AddAccountRequest{Username: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.ModifyVolume(ctx, &sfapi.ModifyVolumeRequest{VolumeID: v.VolumeID, AccountID: otherID}); err != nil {
+		t.Fatal(err)
+	}
+	if vols := getAccount(t, c, accountID).Volumes; len(vols) != 0 {
+		t.Fatalf("expected the volume to leave its old account, got %v", vols)
+	}
+	if vols := getAccount(t, c, otherID).Volumes; len(vols) != 1 || vols[0] != v.VolumeID {
+		t.Fatalf("expected the volume to join the new account, got %v", vols)
+	}
+	if err := c.RemoveAccount(ctx, accountID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveAccount(ctx, otherID); sfapi.ErrorName(err) != "xAccountHasVolumes" {
+		t.Fatalf("expected the account the volume moved to not to be removed, got %v", err)
+	}
+}
@@ -2,120 +2,146 @@ package sfapi
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/alecthomas/units"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
-	"os"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
 	SVIP              string
-	Endpoint          string
+	Endpoint          string   //primary endpoint
+	Endpoints         []string //all endpoints in failover order, primary first
 	DefaultAPIPort    int
 	DefaultVolSize    int64 //bytes
 	DefaultAccountID  int64
 	DefaultTenantName string
 	VolumeTypes       *[]VolType
 	Config            *Config
+	RetryPolicy       RetryPolicy
+	Username          string //credentials for the primary endpoint
+	Password          Secret
+	Metrics           *Metrics //API call metrics, nil disables them
+
+	mu         sync.Mutex
+	apiVersion string
+	active     int //index into Endpoints of the endpoint currently in use
+	nextProbe  time.Time
+	limiter    *limiter
+	auth       []endpointAuth //credentials for each of Endpoints
+
+	httpOnce sync.Once
+	http     *http.Client
+	httpErr  error
 }
 
-type Config struct {
-	TenantName     string
-	EndPoint       string
-	DefaultVolSz   int64 //Default volume size in GiB
-	MountPoint     string
-	SVIP           string
-	InitiatorIFace string //iface to use of iSCSI initiator
-	Types          *[]VolType
+// New returns a Client for the cluster described by conf.  The Client keeps
+// its own copy of conf, so multiple clients (ie for different clusters) can
+// be used side by side in the same process.
+func New(conf Config) (c *Client, err error) {
+	rand.Seed(time.Now().UTC().UnixNano())
+	conf = conf.clone()
+	defSize := conf.DefaultVolSz * int64(units.GiB)
+	var endpoints []string
+	var auth []endpointAuth
+	for _, ep := range conf.endpoints() {
+		clean, user, pass, err := resolveCredentials(&conf, ep)
+		if err != nil {
+			log.Error("Unable to determine API credentials: ", err)
+			return nil, err
+		}
+		endpoints = append(endpoints, clean)
+		auth = append(auth, endpointAuth{username: user, password: pass})
+	}
+	SFClient := &Client{
+		DefaultVolSize:    defSize,
+		SVIP:              conf.SVIP,
+		Config:            &conf,
+		DefaultAPIPort:    443,
+		VolumeTypes:       conf.Types,
+		DefaultTenantName: conf.TenantName,
+		RetryPolicy:       conf.Retry,
+		Endpoints:         endpoints,
+		Metrics:           NewMetrics(),
+		limiter:           newLimiter(conf.RateLimit, conf.RateBurst, conf.MaxInFlight),
+		auth:              auth,
+	}
+	if len(endpoints) > 0 {
+		SFClient.Endpoint = endpoints[0]
+		SFClient.Username, SFClient.Password = auth[0].username, auth[0].password
+		SFClient.apiVersion = endpointVersion(endpoints[0])
+	}
+	if SFClient.apiVersion == "" {
+		SFClient.apiVersion = SupportedAPIVersions[0]
+	}
+	if _, err := SFClient.httpClient(); err != nil {
+		log.Errorf("Unable to set up transport for MVIP %s: %v", mvipHost(SFClient.Endpoint), err)
+		return SFClient, err
+	}
+	return SFClient, nil
 }
 
-type VolType struct {
-	Type string
-	QOS  QoS
+// decodeResponse unmarshals the json-rpc response body of method into result
+func decodeResponse(method string, response []byte, result interface{}) error {
+	if err := json.Unmarshal(response, result); err != nil {
+		log.Errorf("Unable to decode %s response: %v", method, err)
+		return fmt.Errorf("unable to decode %s response: %w", method, err)
+	}
+	return nil
 }
 
-var (
-	endpoint          string
-	svip              string
-	configFile        string
-	defaultTenantName string
-	defaultSizeGiB    int64
-	cfg               Config
-)
-
-func init() {
-	if os.Getenv("SF_CONFIG_FILE") != "" {
-		conf, _ := ProcessConfig(configFile)
-		cfg = conf
-		endpoint = conf.EndPoint
-		svip = conf.SVIP
-		configFile = os.Getenv("SF_CONFIG_FILE")
-		defaultSizeGiB = conf.DefaultVolSz
-		defaultTenantName = conf.TenantName
-	} else {
-		endpoint = os.Getenv("SF_ENDPOINT")
-		svip = os.Getenv("SF_SVIP")
-		configFile = os.Getenv("SF_CONFIG_FILE")
-		defaultSizeGiB, _ = strconv.ParseInt(os.Getenv("SF_DEFAULT_VSIZE"), 10, 64)
-		defaultTenantName = os.Getenv("SF_DEFAULT_TENANT_NAME")
-	}
+func (c *Client) Request(ctx context.Context, method string, params interface{}, id int) (response []byte, err error) {
+	response, _, err = c.requestWithRetry(ctx, method, params, id, nil)
+	return response, err
 }
 
-func ProcessConfig(fname string) (Config, error) {
-	content, err := ioutil.ReadFile(fname)
-	if err != nil {
-		log.Fatal("Error processing config file: ", err)
+// request issues a single attempt of the request, failing over to the next
+// endpoint if the current one can't be reached
+func (c *Client) request(ctx context.Context, method string, params interface{}, id int) (response []byte, err error) {
+	if len(c.Endpoints) == 0 {
+		log.WithFields(log.Fields{"reqID": id, "method": method}).Error("Endpoint is not set, unable to issue requests")
+		err = errors.New("Unable to issue json-rpc requests without specifying Endpoint")
+		return nil, err
 	}
-	var conf Config
-	err = json.Unmarshal(content, &conf)
+	release, err := c.wait(ctx, method)
 	if err != nil {
-		log.Fatal("Error parsing config file: ", err)
+		log.WithFields(log.Fields{"reqID": id, "method": method}).Errorf("Gave up waiting to send request: %v", err)
+		return nil, err
 	}
-	return conf, nil
-}
-
-func NewFromConfig(configFile string) (c *Client, err error) {
-	conf, err := ProcessConfig(configFile)
-	if err != nil {
-		log.Fatal("Error initializing client from Config file: ", configFile, "(", err, ")")
-	}
-	cfg = conf
-	endpoint = conf.EndPoint
-	svip = conf.SVIP
-	configFile = os.Getenv("SF_CONFIG_FILE")
-	defaultSizeGiB = conf.DefaultVolSz
-	defaultTenantName = conf.TenantName
-	return New()
-}
-
-func New() (c *Client, err error) {
-	rand.Seed(time.Now().UTC().UnixNano())
-	defSize := defaultSizeGiB * int64(units.GiB)
-	SFClient := &Client{
-		Endpoint:          endpoint,
-		DefaultVolSize:    defSize,
-		SVIP:              svip,
-		Config:            &cfg,
-		DefaultAPIPort:    443,
-		VolumeTypes:       cfg.Types,
-		DefaultTenantName: defaultTenantName,
+	defer release()
+	for _, idx := range c.endpointOrder() {
+		response, err = c.post(ctx, idx, method, params, id)
+		if err == nil || !isConnError(err) {
+			if err == nil || ErrorName(err) != "" {
+				// we got a json-rpc response, so the endpoint is up
+				c.markHealthy(idx)
+			}
+			return response, err
+		}
+		c.markFailed(idx)
+		// Only move on to the next endpoint if the request can't have been
+		// acted on, anything else is left to the retry policy
+		if !notDelivered(err) || ctx.Err() != nil {
+			return response, err
+		}
 	}
-	return SFClient, nil
+	return response, err
 }
 
-func (c *Client) Request(method string, params interface{}, id int) (response []byte, err error) {
-	log.Debug("Issue request to SolidFire Endpoint...")
-	if c.Endpoint == "" {
-		log.Error("Endpoint is not set, unable to issue requests")
-		err = errors.New("Unable to issue json-rpc requests without specifying Endpoint")
+func (c *Client) post(ctx context.Context, idx int, method string, params interface{}, id int) (response []byte, err error) {
+	endpoint := c.Endpoints[idx]
+	reqLog := log.WithFields(log.Fields{"reqID": id, "method": method})
+	reqLog.Debug("Issue request to SolidFire Endpoint...")
+	url, err := withEndpointVersion(endpoint, c.requestVersion(ctx))
+	if err != nil {
 		return nil, err
 	}
 	data, err := json.Marshal(map[string]interface{}{
@@ -123,18 +149,31 @@ func (c *Client) Request(method string, params interface{}, id int) (response []
 		"id":     id,
 		"params": params,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	Http, err := c.httpClient()
+	if err != nil {
+		reqLog.Errorf("Unable to set up transport for MVIP %s: %v", mvipHost(endpoint), err)
+		return nil, err
 	}
 
-	log.Debugf("POST request to: %+v", c.Endpoint)
-	Http := &http.Client{Transport: tr}
-	resp, err := Http.Post(c.Endpoint,
-		"json-rpc",
-		strings.NewReader(string(data)))
+	reqLog.Debugf("POST request to: %+v", url)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "json-rpc")
+	if username, password := c.credentials(idx); username != "" {
+		req.SetBasicAuth(username, string(password))
+	}
+	resp, err := Http.Do(req)
 	if err != nil {
-		log.Errorf("Error encountered posting request: %v", err)
+		if isTLSVerifyError(err) {
+			err = fmt.Errorf("TLS verification of MVIP %s failed: %v", mvipHost(endpoint), err)
+		}
+		reqLog.Errorf("Error encountered posting request: %v", err)
 		return nil, err
 	}
 
@@ -144,23 +183,34 @@ func (c *Client) Request(method string, params interface{}, id int) (response []
 		return body, err
 	}
 
-	var prettyJson bytes.Buffer
-	_ = json.Indent(&prettyJson, body, "", "  ")
-	log.WithField("", prettyJson.String()).Debug("request:", id, " method:", method, " params:", params)
+	reqLog.WithField("", redactJSON(body)).Debug("request:", id, " method:", method, " params:", redactParams(params))
 
-	errresp := APIError{}
-	json.Unmarshal([]byte(body), &errresp)
-	if errresp.Error.Code != 0 {
-		err = errors.New("Received error response from API request")
-		return body, err
+	errresp := APIErrorResponse{}
+	if err := json.Unmarshal(body, &errresp); err != nil {
+		reqLog.Errorf("Invalid json-rpc response (HTTP %s): %v", resp.Status, err)
+		return body, fmt.Errorf("invalid json-rpc response to %s request %d (HTTP %s): %v", method, id, resp.Status, err)
+	}
+	if errresp.Id != id {
+		reqLog.Errorf("Response id %d does not match request id %d", errresp.Id, id)
+		return body, fmt.Errorf("response id %d does not match %s request id %d", errresp.Id, method, id)
+	}
+	if errresp.Error != nil {
+		errresp.Error.Method = method
+		reqLog.Errorf("Received error response from API request %d (%s): %s", id, method, errresp.Error.Name)
+		return body, errresp.Error
 	}
 	return body, nil
 }
 
+var lastReqID int64
+
+// newReqID returns the next json-rpc request id.  IDs are unique and
+// increasing for the life of the process so daemon logs can be matched up
+// with the cluster's API log.
 func newReqID() int {
-	return rand.Intn(1000-1) + 1
+	return int(atomic.AddInt64(&lastReqID, 1))
 }
 
 func NewReqID() int {
-	return rand.Intn(1000-1) + 1
+	return newReqID()
 }
@@ -0,0 +1,82 @@
+package sfapi
+
+import (
+	"context"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	asyncPollInitial = time.Second
+	asyncPollMax     = 10 * time.Second
+)
+
+// ProgressFunc is called with the latest status each time WaitForAsync polls
+// a long running operation
+type ProgressFunc func(AsyncResult)
+
+// PercentComplete returns the completion percentage reported in the result
+// details, if the operation reports one
+func (r AsyncResult) PercentComplete() (int, bool) {
+	pct, ok := r.Details["percentComplete"].(float64)
+	return int(pct), ok
+}
+
+func (c *Client) GetAsyncResult(ctx context.Context, req *GetAsyncResultRequest) (result AsyncResult, err error) {
+	response, err := c.Request(ctx, "GetAsyncResult", req, newReqID())
+	if err != nil {
+		return result, err
+	}
+	var r GetAsyncResultResult
+	if err := decodeResponse("GetAsyncResult", response, &r); err != nil {
+		return result, err
+	}
+	return r.Result, nil
+}
+
+func (c *Client) ListAsyncResults(ctx context.Context, req *ListAsyncResultsRequest) (handles []AsyncHandle, err error) {
+	response, err := c.Request(ctx, "ListAsyncResults", req, newReqID())
+	if err != nil {
+		return nil, err
+	}
+	var result ListAsyncResultsResult
+	if err := decodeResponse("ListAsyncResults", response, &result); err != nil {
+		return nil, err
+	}
+	return result.Result.AsyncHandles, nil
+}
+
+// WaitForAsync polls the async handle until the cluster reports the
+// operation complete, calling progress (if not nil) after every poll.  If
+// the operation failed the cluster's error is returned as an *APIError.
+func (c *Client) WaitForAsync(ctx context.Context, handle int64, progress ProgressFunc) (result AsyncResult, err error) {
+	req := &GetAsyncResultRequest{AsyncHandle: handle}
+	delay := asyncPollInitial
+	for {
+		result, err = c.GetAsyncResult(ctx, req)
+		if err != nil {
+			return result, err
+		}
+		if progress != nil {
+			progress(result)
+		}
+		if result.Error != nil {
+			result.Error.Method = result.ResultType
+			log.Errorf("Async operation %d (%s) failed: %v", handle, result.ResultType, result.Error)
+			return result, result.Error
+		}
+		if result.Status == "complete" {
+			return result, nil
+		}
+		log.Debugf("Async operation %d (%s) is %s, checking again in %v", handle, result.ResultType, result.Status, delay)
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > asyncPollMax {
+			delay = asyncPollMax
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package sfapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Cassette modes
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// Interaction is a single recorded json-rpc request and the cluster's
+// response to it.  Secrets are masked and the json-rpc ids are dropped.
+type Interaction struct {
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Cassette is an http.RoundTripper that either records the json-rpc
+// requests passing through it to a file, or replays a previously recorded
+// file in place of talking to a cluster.
+//
+// On replay a request is matched on its method and params, if the same
+// request was recorded more than once the responses are returned in the
+// order they were recorded, with the last one repeated once they run out.
+// Secrets are masked in the recording, so replayed responses carry "xxxx"
+// in place of values such as an account's CHAP secrets.
+type Cassette struct {
+	File string `json:"-"`
+	Mode string `json:"-"`
+
+	mu           sync.Mutex
+	next         http.RoundTripper
+	Interactions []Interaction `json:"interactions"`
+	played       map[string]int
+	out          *os.File
+	end          int64 //offset of the closing brackets in out
+}
+
+// NewCassette opens a cassette for recording (wrapping next) or replay.
+// Recording starts a new file, replaying reads the existing one.
+func NewCassette(file, mode string, next http.RoundTripper) (*Cassette, error) {
+	cas := &Cassette{File: file, Mode: mode, next: next, played: map[string]int{}}
+	switch mode {
+	case CassetteRecord:
+		if next == nil {
+			cas.next = http.DefaultTransport
+		}
+		return cas, cas.create()
+	case CassetteReplay:
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, cas); err != nil {
+			return nil, fmt.Errorf("unable to parse cassette %s: %w", file, err)
+		}
+		// the file is indented (and may have been edited by hand), so
+		// compact the params for matching
+		for i := range cas.Interactions {
+			params, err := canonicalJSON(cas.Interactions[i].Params)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse cassette %s: %w", file, err)
+			}
+			cas.Interactions[i].Params = params
+		}
+		return cas, nil
+	}
+	return nil, fmt.Errorf("unknown cassette mode %q, expected %q or %q", mode, CassetteRecord, CassetteReplay)
+}
+
+// rpcMessage is the part of a json-rpc request or response a cassette
+// cares about
+type rpcMessage struct {
+	Method string          `json:"method,omitempty"`
+	Id     json.RawMessage `json:"id,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// canonicalJSON re-encodes data with secrets masked and object keys sorted
+// so equivalent documents compare equal
+func canonicalJSON(data []byte) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(v))
+}
+
+func interactionKey(method string, params json.RawMessage) string {
+	return method + " " + string(params)
+}
+
+func (cas *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("cassette: request is not json-rpc: %w", err)
+	}
+	params, err := canonicalJSON(msg.Params)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: request is not json-rpc: %w", err)
+	}
+	if cas.Mode == CassetteReplay {
+		return cas.replay(req, msg, params)
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp, err := cas.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	recorded, err := canonicalJSON(data)
+	if err != nil {
+		// not json (ie an auth failure page), keep it as a string
+		recorded, _ = json.Marshal(string(data))
+	} else {
+		recorded = withoutID(recorded)
+	}
+	cas.mu.Lock()
+	defer cas.mu.Unlock()
+	i := Interaction{
+		Method:   msg.Method,
+		Params:   params,
+		Status:   resp.StatusCode,
+		Response: recorded,
+	}
+	if err := cas.write(i); err != nil {
+		return nil, err
+	}
+	cas.Interactions = append(cas.Interactions, i)
+	return resp, nil
+}
+
+func (cas *Cassette) replay(req *http.Request, msg rpcMessage, params json.RawMessage) (*http.Response, error) {
+	cas.mu.Lock()
+	defer cas.mu.Unlock()
+	key := interactionKey(msg.Method, params)
+	var matches []Interaction
+	for _, i := range cas.Interactions {
+		if interactionKey(i.Method, i.Params) == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("cassette %s has no recording of %s with params %s", cas.File, msg.Method, params)
+	}
+	n := cas.played[key]
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	cas.played[key]++
+	i := matches[n]
+
+	var data []byte
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(i.Response, &resp); err == nil {
+		resp["id"] = msg.Id
+		data, _ = json.Marshal(resp)
+	} else {
+		var s string
+		json.Unmarshal(i.Response, &s)
+		data = []byte(s)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// withoutID drops the json-rpc id from a response, it's replaced with the
+// id of the replayed request
+func withoutID(data json.RawMessage) json.RawMessage {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return data
+	}
+	delete(resp, "id")
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+const (
+	cassetteHeader = "{\n  \"interactions\": [\n"
+	cassetteFooter = "\n  ]\n}\n"
+)
+
+// create starts a new, empty, recording
+func (cas *Cassette) create() error {
+	f, err := os.OpenFile(cas.File, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write cassette: %w", err)
+	}
+	if _, err := f.WriteString(cassetteHeader + cassetteFooter); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write cassette: %w", err)
+	}
+	cas.out = f
+	cas.end = int64(len(cassetteHeader))
+	return nil
+}
+
+// write adds an interaction to the end of the recording, overwriting the
+// closing brackets and putting them back after it, so the file is complete
+// after every request even if the process doesn't exit cleanly
+func (cas *Cassette) write(i Interaction) error {
+	if cas.out == nil {
+		return fmt.Errorf("cassette %s is closed", cas.File)
+	}
+	data, err := json.MarshalIndent(i, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := "    "
+	if len(cas.Interactions) > 0 {
+		sep = ",\n    "
+	}
+	entry := append([]byte(sep), data...)
+	if _, err := cas.out.WriteAt(append(entry, cassetteFooter...), cas.end); err != nil {
+		return fmt.Errorf("unable to write cassette: %w", err)
+	}
+	cas.end += int64(len(entry))
+	return nil
+}
+
+// Close closes a recording cassette's file, further requests fail.  It
+// does nothing when replaying.
+func (cas *Cassette) Close() error {
+	cas.mu.Lock()
+	defer cas.mu.Unlock()
+	if cas.out == nil {
+		return nil
+	}
+	err := cas.out.Close()
+	cas.out = nil
+	return err
+}
@@ -0,0 +1,181 @@
+package sfapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+	"github.com/solidfire/solidfire-docker-driver/sfapi/fake"
+)
+
+// recordCassette records a session against a fake cluster, returning the
+// config to replay it and the volume created during the session
+func recordCassette(t *testing.T) (sfapi.Config, sfapi.Volume) {
+	t.Helper()
+	fc := fake.NewCluster()
+	defer fc.Close()
+	fc.AsyncPolls = 0
+	conf := fc.Config()
+	conf.CassetteFile = filepath.Join(t.TempDir(), "cassette.json")
+	conf.CassetteMode = sfapi.CassetteRecord
+	c, err := sfapi.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.NegotiateAPIVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	accountID, err := c.AddAccount(ctx, &sfapi.AddAccountRequest{Username: "docker", InitiatorSecret: "recordedsecret1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vols, err := c.ListActiveVolumes(ctx, &sfapi.ListActiveVolumesRequest{}); err != nil || len(vols) != 0 {
+		t.Fatalf("expected no volumes, got %v, %v", vols, err)
+	}
+	v := createVolume(t, c, accountID, "data")
+	if vols, err := c.ListActiveVolumes(ctx, &sfapi.ListActiveVolumesRequest{}); err != nil || len(vols) != 1 {
+		t.Fatalf("expected 1 volume, got %v, %v", vols, err)
+	}
+	return conf, v
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	conf, recorded := recordCassette(t)
+	data, err := ioutil.ReadFile(conf.CassetteFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "recordedsecret1") {
+		t.Fatal("expected secrets to be masked in the cassette")
+	}
+
+	// The fake cluster is gone, everything comes from the cassette
+	conf.CassetteMode = sfapi.CassetteReplay
+	c, err := sfapi.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.NegotiateAPIVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.APIVersion() != sfapi.SupportedAPIVersions[len(sfapi.SupportedAPIVersions)-1] {
+		t.Fatalf("expected the recorded API version, got %s", c.APIVersion())
+	}
+	// Secrets are masked before matching, so a different one still matches
+	accountID, err := c.AddAccount(ctx, &sfapi.AddAccountRequest{Username: "docker", InitiatorSecret: "othersecret12"})
+	if err != nil || accountID != recorded.AccountID {
+		t.Fatalf("AddAccount: %d, %v", accountID, err)
+	}
+
+	// Repeated requests get their responses in the order they were
+	// recorded, with the last one repeated
+	for _, want := range []int{0, 1, 1} {
+		vols, err := c.ListActiveVolumes(ctx, &sfapi.ListActiveVolumesRequest{})
+		if err != nil || len(vols) != want {
+			t.Fatalf("expected %d volumes, got %v, %v", want, vols, err)
+		}
+	}
+	v := createVolume(t, c, accountID, "data")
+	if v.VolumeID != recorded.VolumeID || v.Name != "data" {
+		t.Fatalf("expected the recorded volume %+v, got %+v", recorded, v)
+	}
+}
+
+func TestCassetteWrittenAsRecorded(t *testing.T) {
+	fc := fake.NewCluster()
+	defer fc.Close()
+	file := filepath.Join(t.TempDir(), "cassette.json")
+	cas, err := sfapi.NewCassette(file, sfapi.CassetteRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := fc.Config()
+	c, err := sfapi.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := &http.Client{Transport: cas}
+	for n := 0; n <= 3; n++ {
+		if n > 0 {
+			body := fmt.Sprintf(`{"method": "ListActiveVolumes", "id": %d, "params": {"limit": %d}}`, n, n)
+			req, err := http.NewRequest("POST", c.Endpoint, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetBasicAuth(conf.Username, string(conf.Password))
+			resp, err := hc.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+		// The file is complete after every request
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var recorded sfapi.Cassette
+		if err := json.Unmarshal(data, &recorded); err != nil {
+			t.Fatalf("after %d requests: %v\n%s", n, err, data)
+		}
+		if len(recorded.Interactions) != n {
+			t.Fatalf("expected %d interactions, got %d", n, len(recorded.Interactions))
+		}
+		if n > 0 {
+			var params bytes.Buffer
+			json.Compact(&params, recorded.Interactions[n-1].Params)
+			if params.String() != fmt.Sprintf(`{"limit":%d}`, n) {
+				t.Fatalf("expected the requests in order, got %s", params.String())
+			}
+		}
+	}
+
+	if err := cas.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("POST", c.Endpoint, strings.NewReader(`{"method": "GetClusterInfo", "id": 1}`))
+	if _, err := hc.Do(req); err == nil {
+		t.Fatal("expected recording to a closed cassette to fail")
+	}
+}
+
+func TestCassetteReplayMismatch(t *testing.T) {
+	conf, recorded := recordCassette(t)
+	conf.CassetteMode = sfapi.CassetteReplay
+	c, err := sfapi.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CreateVolume(context.Background(), &sfapi.CreateVolumeRequest{Name: "other", AccountID: recorded.AccountID, TotalSize: 1 << 30})
+	if err == nil || !strings.Contains(err.Error(), "has no recording of CreateVolume") {
+		t.Fatalf("expected a replay mismatch error, got %v", err)
+	}
+	if err := c.DeleteVolume(context.Background(), recorded.VolumeID); err == nil {
+		t.Fatal("expected a method that was never recorded to fail")
+	}
+}
+
+func TestCassetteErrors(t *testing.T) {
+	if _, err := sfapi.NewCassette(filepath.Join(t.TempDir(), "missing.json"), sfapi.CassetteReplay, nil); err == nil {
+		t.Fatal("expected replaying a missing cassette to fail")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := ioutil.WriteFile(bad, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sfapi.NewCassette(bad, sfapi.CassetteReplay, nil); err == nil {
+		t.Fatal("expected replaying a corrupt cassette to fail")
+	}
+	if _, err := sfapi.NewCassette(bad, "rewind", nil); err == nil {
+		t.Fatal("expected an unknown mode to fail")
+	}
+}
@@ -0,0 +1,132 @@
+package sfapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func (c *Client) GetClusterVersionInfo(ctx context.Context) (info GetClusterVersionInfoResult, err error) {
+	response, err := c.Request(ctx, "GetClusterVersionInfo", struct{}{}, newReqID())
+	if err != nil {
+		return info, err
+	}
+	if err := decodeResponse("GetClusterVersionInfo", response, &info); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+func (c *Client) GetClusterInfo(ctx context.Context) (info ClusterInfo, err error) {
+	response, err := c.Request(ctx, "GetClusterInfo", struct{}{}, newReqID())
+	if err != nil {
+		return info, err
+	}
+	var result GetClusterInfoResult
+	if err := decodeResponse("GetClusterInfo", response, &result); err != nil {
+		return info, err
+	}
+	return result.Result.ClusterInfo, nil
+}
+
+func (c *Client) GetClusterCapacity(ctx context.Context) (capacity ClusterCapacity, err error) {
+	response, err := c.Request(ctx, "GetClusterCapacity", struct{}{}, newReqID())
+	if err != nil {
+		return capacity, err
+	}
+	var result GetClusterCapacityResult
+	if err := decodeResponse("GetClusterCapacity", response, &result); err != nil {
+		return capacity, err
+	}
+	return result.Result.ClusterCapacity, nil
+}
+
+func (c *Client) GetClusterFullThreshold(ctx context.Context) (threshold ClusterFullThreshold, err error) {
+	response, err := c.Request(ctx, "GetClusterFullThreshold", struct{}{}, newReqID())
+	if err != nil {
+		return threshold, err
+	}
+	var result GetClusterFullThresholdResult
+	if err := decodeResponse("GetClusterFullThreshold", response, &result); err != nil {
+		return threshold, err
+	}
+	return result.Result, nil
+}
+
+// ThinProvisioning is the ratio of provisioned to written blocks
+func (cc ClusterCapacity) ThinProvisioning() float64 {
+	if cc.NonZeroBlocks == 0 {
+		return 1
+	}
+	return float64(cc.NonZeroBlocks+cc.ZeroBlocks) / float64(cc.NonZeroBlocks)
+}
+
+// Deduplication is the ratio of written blocks, including those of
+// snapshots, to the unique blocks stored
+func (cc ClusterCapacity) Deduplication() float64 {
+	if cc.UniqueBlocks == 0 {
+		return 1
+	}
+	return float64(cc.NonZeroBlocks+cc.SnapshotNonZeroBlocks) / float64(cc.UniqueBlocks)
+}
+
+// Compression is the ratio of the size of the unique blocks to the space
+// they use once compressed
+func (cc ClusterCapacity) Compression() float64 {
+	if cc.UniqueBlocksUsedSpace == 0 {
+		return 1
+	}
+	// The used space includes ~7% of overhead per the Element docs
+	return float64(cc.UniqueBlocks*4096) / (float64(cc.UniqueBlocksUsedSpace) * 0.93)
+}
+
+// FullnessStage returns the number of a fullness stage such as
+// "stage3Low", 0 if it isn't one
+func FullnessStage(fullness string) int {
+	if !strings.HasPrefix(fullness, "stage") || len(fullness) < 6 {
+		return 0
+	}
+	n, err := strconv.Atoi(fullness[5:6])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// fullStage is the fullness stage (stage4Critical) at which the cluster
+// raises an error fault and new volumes are refused
+const fullStage = 4
+
+// CheckCapacity returns an error wrapping ErrClusterFull if the cluster's
+// block or metadata space is at or past the critical fullness stage, or if
+// provisioning size more bytes would exceed its maximum provisioned space
+func (c *Client) CheckCapacity(ctx context.Context, size int64) error {
+	threshold, err := c.GetClusterFullThreshold(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct{ kind, stage string }{
+		{"block", threshold.BlockFullness},
+		{"metadata", threshold.MetadataFullness},
+	} {
+		if FullnessStage(f.stage) >= fullStage {
+			err := fmt.Errorf("%w: %s space is at %s; free space on the cluster before creating volumes", ErrClusterFull, f.kind, f.stage)
+			log.Error(err)
+			return err
+		}
+	}
+	capacity, err := c.GetClusterCapacity(ctx)
+	if err != nil {
+		return err
+	}
+	if capacity.MaxProvisionedSpace > 0 && capacity.ProvisionedSpace+size > capacity.MaxProvisionedSpace {
+		err := fmt.Errorf("%w: provisioning %d more bytes would exceed the cluster's maximum provisioned space (%d of %d bytes in use)",
+			ErrClusterFull, size, capacity.ProvisionedSpace, capacity.MaxProvisionedSpace)
+		log.Error(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package sfapi
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+type Config struct {
+	TenantName     string
+	EndPoint       string
+	EndPoints      []string //additional MVIP endpoints to fail over to, in order
+	DefaultVolSz   int64    //Default volume size in GiB
+	MountPoint     string
+	SVIP           string
+	InitiatorIFace string //iface to use of iSCSI initiator
+	MetricsAddress string //address for the daemon to serve Prometheus metrics on, ie ":9433"
+	Types          *[]VolType
+	Retry          RetryPolicy
+	ListPageSize   int64 //number of volumes fetched per request when listing
+	PurgeOnRemove  bool  //purge volumes on docker volume rm rather than leave them restorable
+
+	EndpointProbeSecs int64 //how often to retry the primary endpoint after a failover
+
+	// Limits on the requests sent to the cluster, 0 disables a limit
+	RateLimit   float64 //requests per second
+	RateBurst   int     //requests that may be sent at once before RateLimit applies
+	MaxInFlight int     //requests outstanding at once
+
+	// API credentials, if not set they're taken from the EndPoint URL.
+	// The *From variants name a secret source instead, ie "env:SF_PASSWORD",
+	// "file:/run/secrets/sf_password" or "cmd:/usr/local/bin/sf-password"
+	Username     string
+	Password     Secret
+	UsernameFrom string
+	PasswordFrom string
+
+	ConnectTimeoutSecs   int64 //timeout for establishing connections to the MVIP
+	RequestTimeoutSecs   int64 //overall timeout of a single API request
+	OperationTimeoutSecs int64 //deadline for the cluster calls made servicing one Docker request
+
+	// TLS settings for the connection to the MVIP
+	CACertFile         string //PEM bundle of additional trusted CAs
+	CertFingerprint    string //SHA-256 of the MVIP certificate(s) to pin, comma separated
+	InsecureSkipVerify bool   //explicitly disable certificate verification
+	ClientCertFile     string
+	ClientKeyFile      string
+
+	// Record the json-rpc traffic to CassetteFile, or replay it from there
+	// instead of talking to the cluster ("record" or "replay")
+	CassetteFile string
+	CassetteMode string
+}
+
+type VolType struct {
+	Type      string
+	QOS       QoS
+	QoSPolicy string //name of a cluster QoS policy to use instead of QOS
+}
+
+// clone returns a copy of the Config that shares no references with c
+func (c Config) clone() Config {
+	if c.Types != nil {
+		types := make([]VolType, len(*c.Types))
+		copy(types, *c.Types)
+		c.Types = &types
+	}
+	c.EndPoints = append([]string(nil), c.EndPoints...)
+	return c
+}
+
+// endpoints returns EndPoint followed by EndPoints, without duplicates
+func (c Config) endpoints() []string {
+	var eps []string
+	seen := map[string]bool{}
+	for _, ep := range append([]string{c.EndPoint}, c.EndPoints...) {
+		if ep != "" && !seen[ep] {
+			seen[ep] = true
+			eps = append(eps, ep)
+		}
+	}
+	return eps
+}
+
+// ProcessConfig loads a Config from a json config file
+func ProcessConfig(fname string) (Config, error) {
+	var conf Config
+	content, err := ioutil.ReadFile(fname)
+	if err != nil {
+		log.Error("Error processing config file: ", err)
+		return conf, fmt.Errorf("unable to read config file %s: %w", fname, err)
+	}
+	err = json.Unmarshal(content, &conf)
+	if err != nil {
+		log.Error("Error parsing config file: ", err)
+		return conf, fmt.Errorf("unable to parse config file %s: %w", fname, err)
+	}
+	return conf, nil
+}
+
+// ConfigFromEnv loads a Config from the environment.  If SF_CONFIG_FILE is
+// set that file is used, otherwise the individual SF_* variables are read.
+func ConfigFromEnv() (Config, error) {
+	if fname := os.Getenv("SF_CONFIG_FILE"); fname != "" {
+		return ProcessConfig(fname)
+	}
+	var conf Config
+	conf.EndPoint = os.Getenv("SF_ENDPOINT")
+	conf.SVIP = os.Getenv("SF_SVIP")
+	conf.TenantName = os.Getenv("SF_DEFAULT_TENANT_NAME")
+	conf.DefaultVolSz, _ = strconv.ParseInt(os.Getenv("SF_DEFAULT_VSIZE"), 10, 64)
+	conf.Username = os.Getenv("SF_LOGIN")
+	conf.Password = Secret(os.Getenv("SF_PASSWORD"))
+	conf.CACertFile = os.Getenv("SF_CA_CERT_FILE")
+	conf.CertFingerprint = os.Getenv("SF_CERT_FINGERPRINT")
+	conf.InsecureSkipVerify, _ = strconv.ParseBool(os.Getenv("SF_INSECURE"))
+	return conf, nil
+}
+
+// NewFromConfig returns a Client configured from a json config file
+func NewFromConfig(configFile string) (c *Client, err error) {
+	conf, err := ProcessConfig(configFile)
+	if err != nil {
+		log.Error("Error initializing client from Config file: ", configFile, "(", err, ")")
+		return nil, err
+	}
+	return New(conf)
+}
+
+// NewFromEnv returns a Client configured from the environment, see
+// ConfigFromEnv
+func NewFromEnv() (c *Client, err error) {
+	conf, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return New(conf)
+}
@@ -0,0 +1,226 @@
+package sfapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Secret is a string that is never printed by fmt or the logger, use
+// string(s) to get at the actual value
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "xxxx"
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// SecretSource resolves the argument of a secret reference (the part after
+// "scheme:") to the secret value
+type SecretSource func(arg string) (string, error)
+
+var (
+	secretSourcesMu sync.RWMutex
+	secretSources   = map[string]SecretSource{
+		"env":  envSecret,
+		"file": fileSecret,
+		"cmd":  cmdSecret,
+	}
+)
+
+// RegisterSecretSource adds (or replaces) the source used to resolve secret
+// references of the form scheme:arg
+func RegisterSecretSource(scheme string, src SecretSource) {
+	secretSourcesMu.Lock()
+	defer secretSourcesMu.Unlock()
+	secretSources[scheme] = src
+}
+
+// ResolveSecret resolves a secret reference such as "env:SF_PASSWORD",
+// "file:/run/secrets/sf_password" or "cmd:/usr/local/bin/sf-password"
+func ResolveSecret(ref string) (Secret, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid secret reference %q, expected <source>:<value>", ref)
+	}
+	secretSourcesMu.RLock()
+	src, ok := secretSources[parts[0]]
+	secretSourcesMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret source %q", parts[0])
+	}
+	val, err := src(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %s secret: %v", parts[0], err)
+	}
+	return Secret(val), nil
+}
+
+func envSecret(name string) (string, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return val, nil
+}
+
+func fileSecret(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func cmdSecret(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", args[0], err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// resolveCredentials works out the API credentials from the Config.  An
+// explicit Username/Password wins, then UsernameFrom/PasswordFrom, and
+// finally any credentials embedded in the endpoint URL.  Configured
+// credentials replace embedded ones as a pair, setting only the username or
+// only the password for an endpoint that embeds both is an error.  The
+// endpoint is returned with the credentials stripped so it's safe to log.
+func resolveCredentials(conf *Config, endpoint string) (clean, username string, password Secret, err error) {
+	clean = endpoint
+	embedded := false
+	if u, perr := url.Parse(endpoint); perr == nil && u.User != nil {
+		embedded = true
+		username = u.User.Username()
+		p, _ := u.User.Password()
+		password = Secret(p)
+		u.User = nil
+		clean = u.String()
+	}
+	if conf == nil {
+		return clean, username, password, nil
+	}
+
+	var confUsername string
+	if conf.Username != "" {
+		confUsername = conf.Username
+	} else if conf.UsernameFrom != "" {
+		s, err := ResolveSecret(conf.UsernameFrom)
+		if err != nil {
+			return clean, "", "", fmt.Errorf("UsernameFrom: %v", err)
+		}
+		confUsername = string(s)
+	}
+
+	confPassword := conf.Password
+	if confPassword == "" && conf.PasswordFrom != "" {
+		confPassword, err = ResolveSecret(conf.PasswordFrom)
+		if err != nil {
+			return clean, "", "", fmt.Errorf("PasswordFrom: %v", err)
+		}
+	}
+
+	switch {
+	case confUsername == "" && confPassword == "":
+	case embedded && (confUsername == "" || confPassword == ""):
+		return clean, "", "", fmt.Errorf("%s embeds credentials, the configured username and password must both be set to override them", clean)
+	default:
+		username, password = confUsername, confPassword
+	}
+	return clean, username, password, nil
+}
+
+// endpointAuth holds the API credentials for one endpoint, failover
+// endpoints (ie a DR cluster's MVIP) may embed their own
+type endpointAuth struct {
+	username string
+	password Secret
+}
+
+// credentials returns the credentials for the endpoint at idx, the primary
+// endpoint's Username and Password if it has none of its own
+func (c *Client) credentials(idx int) (string, Secret) {
+	if idx > 0 && idx < len(c.auth) && c.auth[idx].username != "" {
+		return c.auth[idx].username, c.auth[idx].password
+	}
+	return c.Username, c.Password
+}
+
+var secretKeys = []string{"secret", "password", "passwd"}
+
+func isSecretKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range secretKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if isSecretKey(k) {
+				if _, ok := val.(string); ok {
+					t[k] = "xxxx"
+					continue
+				}
+			}
+			t[k] = redactValue(val)
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redactValue(t[i])
+		}
+	}
+	return v
+}
+
+// redactJSON returns an indented copy of a json document with the values of
+// any secret looking keys (initiatorSecret, password...) masked, suitable
+// for logging
+func redactJSON(data []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "<unparseable json>"
+	}
+	out, err := json.MarshalIndent(redactValue(v), "", "  ")
+	if err != nil {
+		return "<unparseable json>"
+	}
+	return string(out)
+}
+
+// redactParams is redactJSON for a value that hasn't been marshalled yet
+func redactParams(params interface{}) string {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("%T", params)
+	}
+	return redactJSON(data)
+}
+
+// redactString masks every occurrence of secret in s
+func redactString(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	return strings.Replace(s, secret, "xxxx", -1)
+}
@@ -0,0 +1,76 @@
+package sfapi
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const defaultProbeInterval = time.Minute
+
+// endpointOrder returns the indexes of the endpoints in the order they
+// should be tried: the endpoint that last worked first, followed by the
+// rest.  Once the probe interval has passed after a failover the primary is
+// put back at the front so we return to it when it's healthy again.
+func (c *Client) endpointOrder() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	start := c.active
+	if c.active != 0 && time.Now().After(c.nextProbe) {
+		log.Debugf("Re-probing primary endpoint %s", mvipHost(c.Endpoints[0]))
+		start = 0
+		c.nextProbe = time.Now().Add(c.probeInterval())
+	}
+	order := make([]int, 0, len(c.Endpoints))
+	for i := range c.Endpoints {
+		order = append(order, (start+i)%len(c.Endpoints))
+	}
+	return order
+}
+
+// markHealthy records that the endpoint at idx just serviced a request
+func (c *Client) markHealthy(idx int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if idx == c.active {
+		return
+	}
+	if idx == 0 {
+		log.Infof("Primary endpoint %s is healthy again, switching back to it", mvipHost(c.Endpoints[0]))
+	} else {
+		log.Warningf("Failed over from endpoint %s to %s", mvipHost(c.Endpoints[c.active]), mvipHost(c.Endpoints[idx]))
+		c.nextProbe = time.Now().Add(c.probeInterval())
+	}
+	c.active = idx
+}
+
+// markFailed records a connection failure to the endpoint at idx so the
+// next request starts with the following endpoint
+func (c *Client) markFailed(idx int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if idx != c.active || len(c.Endpoints) < 2 {
+		return
+	}
+	next := (idx + 1) % len(c.Endpoints)
+	log.Warningf("Endpoint %s is unreachable, failing over to %s", mvipHost(c.Endpoints[idx]), mvipHost(c.Endpoints[next]))
+	c.active = next
+	c.nextProbe = time.Now().Add(c.probeInterval())
+}
+
+// activeEndpoint returns the endpoint requests are currently sent to
+func (c *Client) activeEndpoint() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.Endpoints) == 0 {
+		return ""
+	}
+	return c.Endpoints[c.active]
+}
+
+func (c *Client) probeInterval() time.Duration {
+	if c.Config != nil && c.Config.EndpointProbeSecs > 0 {
+		return time.Duration(c.Config.EndpointProbeSecs) * time.Second
+	}
+	return defaultProbeInterval
+}
@@ -0,0 +1,67 @@
+package sfapi_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+	"github.com/solidfire/solidfire-docker-driver/sfapi/fake"
+)
+
+// withCredentials returns the fake cluster's endpoint with its credentials
+// embedded
+func withCredentials(fc *fake.Cluster) string {
+	return strings.Replace(fc.Endpoint("7.0"), "://", "://"+fc.Username+":"+fc.Password+"@", 1)
+}
+
+func TestFailoverUsesEndpointCredentials(t *testing.T) {
+	primary := fake.NewCluster()
+	defer primary.Close()
+	dr := fake.NewCluster()
+	defer dr.Close()
+	dr.Username, dr.Password = "dradmin", "drpassword"
+
+	c, err := sfapi.New(sfapi.Config{
+		EndPoint:  withCredentials(primary),
+		EndPoints: []string{withCredentials(dr)},
+		Retry:     sfapi.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Username != primary.Username {
+		t.Fatalf("expected the primary's credentials, got %s", c.Username)
+	}
+
+	primary.Close()
+	if _, err := c.GetClusterInfo(context.Background()); err != nil {
+		t.Fatalf("expected failover to the DR endpoint with its own credentials, got %v", err)
+	}
+	if dr.Calls("GetClusterInfo") != 1 {
+		t.Fatalf("expected the request to fail over, the DR cluster saw %d", dr.Calls("GetClusterInfo"))
+	}
+}
+
+func TestFailoverSharesPrimaryCredentials(t *testing.T) {
+	primary := fake.NewCluster()
+	defer primary.Close()
+	dr := fake.NewCluster()
+	defer dr.Close()
+
+	c, err := sfapi.New(sfapi.Config{
+		EndPoint:  withCredentials(primary),
+		EndPoints: []string{dr.Endpoint("7.0")},
+		Retry:     sfapi.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(c.Endpoint, primary.Password) {
+		t.Fatalf("expected credentials to be stripped from %s", c.Endpoint)
+	}
+	primary.Close()
+	if _, err := c.GetClusterInfo(context.Background()); err != nil {
+		t.Fatalf("expected the DR endpoint to use the primary's credentials, got %v", err)
+	}
+}
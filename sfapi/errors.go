@@ -0,0 +1,77 @@
+package sfapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// APIError is the error object returned by the Element API in a json-rpc
+// error response.  Name is the Element error name (ie xVolumeIDDoesNotExist)
+// and is what callers should branch on.
+type APIError struct {
+	Method  string `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Name    string `json:"name"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed: %s (%s, code %d)", e.Method, e.Message, e.Name, e.Code)
+}
+
+// ErrNotFound is returned (wrapped) by lookups such as GetVolumeByName that
+// find nothing matching, IsNotFound recognizes it as well as the Element
+// DoesNotExist errors
+var ErrNotFound = errors.New("not found")
+
+// ErrClusterFull is returned (wrapped) by CheckCapacity when the cluster
+// doesn't have room for new volumes
+var ErrClusterFull = errors.New("cluster is full")
+
+var (
+	notFoundNames = []string{"DoesNotExist", "NotFound", "xUnknownAccount"}
+	busyNames     = []string{"xUnitIsBusy", "xServiceUnavailable", "xDBConnectionLost", "xSliceNotRegistered"}
+	existsNames   = []string{"AlreadyExists", "Exists", "xDuplicate"}
+)
+
+func matchAPIError(err error, names []string) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, n := range names {
+		if strings.Contains(apiErr.Name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorName returns the Element error name carried by err, or "" if err is
+// not an APIError.
+func ErrorName(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Name
+	}
+	return ""
+}
+
+// IsNotFound reports whether err is an Element error indicating the
+// requested object (volume, account, snapshot, VAG...) does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || matchAPIError(err, notFoundNames)
+}
+
+// IsBusy reports whether err is an Element error indicating the cluster
+// was temporarily unable to service the request.
+func IsBusy(err error) bool {
+	return matchAPIError(err, busyNames)
+}
+
+// IsExists reports whether err is an Element error indicating the object
+// being created already exists.
+func IsExists(err error) bool {
+	return matchAPIError(err, existsNames)
+}
@@ -0,0 +1,48 @@
+package sfapi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorClassification(t *testing.T) {
+	apiErr := func(name string) error {
+		return &APIError{Method: "GetVolumeStats", Code: 500, Name: name, Message: name}
+	}
+	for _, tc := range []struct {
+		err                    error
+		name                   string
+		notFound, busy, exists bool
+	}{
+		{nil, "", false, false, false},
+		{errors.New("connection refused"), "", false, false, false},
+		{ErrNotFound, "", true, false, false},
+		{fmt.Errorf("volume data: %w", ErrNotFound), "", true, false, false},
+		{apiErr("xVolumeIDDoesNotExist"), "xVolumeIDDoesNotExist", true, false, false},
+		{apiErr("xAccountIDDoesNotExist"), "xAccountIDDoesNotExist", true, false, false},
+		{apiErr("xUnknownAccount"), "xUnknownAccount", true, false, false},
+		{fmt.Errorf("lookup: %w", apiErr("xSnapshotNotFound")), "xSnapshotNotFound", true, false, false},
+		{apiErr("xUnitIsBusy"), "xUnitIsBusy", false, true, false},
+		{apiErr("xServiceUnavailable"), "xServiceUnavailable", false, true, false},
+		{fmt.Errorf("retry: %w", apiErr("xDBConnectionLost")), "xDBConnectionLost", false, true, false},
+		{apiErr("xSliceNotRegistered"), "xSliceNotRegistered", false, true, false},
+		{apiErr("xAccountAlreadyExists"), "xAccountAlreadyExists", false, false, true},
+		{apiErr("xDuplicateUsername"), "xDuplicateUsername", false, false, true},
+		{apiErr("xInvalidParameter"), "xInvalidParameter", false, false, false},
+		{apiErr("xUnknown"), "xUnknown", false, false, false},
+	} {
+		if got := ErrorName(tc.err); got != tc.name {
+			t.Errorf("ErrorName(%v) = %q, expected %q", tc.err, got, tc.name)
+		}
+		if got := IsNotFound(tc.err); got != tc.notFound {
+			t.Errorf("IsNotFound(%v) = %t, expected %t", tc.err, got, tc.notFound)
+		}
+		if got := IsBusy(tc.err); got != tc.busy {
+			t.Errorf("IsBusy(%v) = %t, expected %t", tc.err, got, tc.busy)
+		}
+		if got := IsExists(tc.err); got != tc.exists {
+			t.Errorf("IsExists(%v) = %t, expected %t", tc.err, got, tc.exists)
+		}
+	}
+}
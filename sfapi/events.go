@@ -0,0 +1,241 @@
+package sfapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// FaultSeverities are the severities of cluster faults, least severe first
+var FaultSeverities = []string{"bestPractice", "warning", "error", "critical"}
+
+// FaultSeverityLevel returns the position of severity in FaultSeverities,
+// -1 if it's unknown
+func FaultSeverityLevel(severity string) int {
+	for i, s := range FaultSeverities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// FaultFilter selects the faults returned by ListClusterFaults, zero
+// values match everything
+type FaultFilter struct {
+	Since       time.Time //only faults raised at or after Since
+	Until       time.Time //only faults raised before Until
+	VolumeID    int64     //only faults about this volume, or not about any volume
+	MinSeverity string    //only faults at least this severe, ie "error"
+}
+
+// Match reports whether the fault is selected by the filter
+func (f FaultFilter) Match(fault ClusterFault) bool {
+	if f.MinSeverity != "" && FaultSeverityLevel(fault.Severity) < FaultSeverityLevel(f.MinSeverity) {
+		return false
+	}
+	if !inTimeRange(fault.Date, f.Since, f.Until) {
+		return false
+	}
+	if f.VolumeID != 0 {
+		if ids := volumeIDsIn(fault.Data); len(ids) > 0 && !containsVolumeID(ids, f.VolumeID) {
+			return false
+		}
+	}
+	return true
+}
+
+// EventFilter selects the events returned by ListEvents, zero values match
+// everything
+type EventFilter struct {
+	Since       time.Time //only events reported at or after Since
+	Until       time.Time //only events reported before Until
+	VolumeID    int64     //only events about this volume
+	MinSeverity int       //only events at least this severe
+}
+
+// Match reports whether the event is selected by the filter
+func (f EventFilter) Match(e Event) bool {
+	if e.Severity < f.MinSeverity {
+		return false
+	}
+	if !inTimeRange(e.TimeOfReport, f.Since, f.Until) {
+		return false
+	}
+	if f.VolumeID != 0 && !containsVolumeID(volumeIDsIn(e.Details), f.VolumeID) {
+		return false
+	}
+	return true
+}
+
+// inTimeRange reports whether the timestamp ts is within [since, until),
+// timestamps that can't be parsed are always in range
+func inTimeRange(ts string, since, until time.Time) bool {
+	if since.IsZero() && until.IsZero() {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return true
+	}
+	return (since.IsZero() || !t.Before(since)) && (until.IsZero() || t.Before(until))
+}
+
+// volumeIDsIn returns the volume IDs named by "volumeID" or "volumeIDs"
+// keys in the details or data of an event or fault
+func volumeIDsIn(v interface{}) (ids []int64) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			switch k {
+			case "volumeID":
+				if id, ok := val.(float64); ok {
+					ids = append(ids, int64(id))
+				}
+			case "volumeIDs", "volumes":
+				if list, ok := val.([]interface{}); ok {
+					for _, item := range list {
+						if id, ok := item.(float64); ok {
+							ids = append(ids, int64(id))
+						}
+					}
+				}
+			default:
+				ids = append(ids, volumeIDsIn(val)...)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			ids = append(ids, volumeIDsIn(item)...)
+		}
+	}
+	return ids
+}
+
+func containsVolumeID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ListClusterFaults returns the faults of the given type ("current",
+// "resolved" or "all") that match filter, oldest first.  Best practice
+// faults are only returned if the filter's MinSeverity allows them.
+func (c *Client) ListClusterFaults(ctx context.Context, faultTypes string, filter FaultFilter) (faults []ClusterFault, err error) {
+	req := ListClusterFaultsRequest{
+		FaultTypes:    faultTypes,
+		BestPractices: FaultSeverityLevel(filter.MinSeverity) <= 0,
+	}
+	response, err := c.Request(ctx, "ListClusterFaults", &req, newReqID())
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	var result ListClusterFaultsResult
+	if err := decodeResponse("ListClusterFaults", response, &result); err != nil {
+		return nil, err
+	}
+	for _, f := range result.Result.Faults {
+		if filter.Match(f) {
+			faults = append(faults, f)
+		}
+	}
+	sort.SliceStable(faults, func(i, j int) bool { return faults[i].ClusterFaultID < faults[j].ClusterFaultID })
+	return faults, nil
+}
+
+// ListEvents returns the events from the cluster's event log selected by req
+// that also match filter, oldest first
+func (c *Client) ListEvents(ctx context.Context, req *ListEventsRequest, filter EventFilter) (events []Event, err error) {
+	response, err := c.Request(ctx, "ListEvents", req, newReqID())
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	var result ListEventsResult
+	if err := decodeResponse("ListEvents", response, &result); err != nil {
+		return nil, err
+	}
+	for _, e := range result.Result.Events {
+		if filter.Match(e) {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].EventID < events[j].EventID })
+	return events, nil
+}
+
+// ListEventsAfter returns every event logged after afterID, oldest first.
+// The cluster returns the newest events of a range first, so when more than
+// pageSize are waiting the older ones are fetched page by page rather than
+// skipped.
+func (c *Client) ListEventsAfter(ctx context.Context, afterID, pageSize int64) (events []Event, err error) {
+	req := ListEventsRequest{StartEventID: afterID + 1, MaxEvents: pageSize}
+	for {
+		page, err := c.ListEvents(ctx, &req, EventFilter{})
+		if err != nil {
+			return nil, err
+		}
+		var oldest int64
+		for _, e := range page {
+			if e.EventID <= afterID || (req.EndEventID > 0 && e.EventID > req.EndEventID) {
+				continue
+			}
+			events = append(events, e)
+			if oldest == 0 || e.EventID < oldest {
+				oldest = e.EventID
+			}
+		}
+		if pageSize <= 0 || int64(len(page)) < pageSize || oldest <= afterID+1 {
+			break
+		}
+		req.EndEventID = oldest - 1
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].EventID < events[j].EventID })
+	return events, nil
+}
+
+// FaultSummary describes the current faults of at least warning severity
+// that may affect volumeID (0 for faults not about a specific volume), for
+// adding to the error message of a failed operation.  It returns "" if
+// there are none or they can't be retrieved.
+func (c *Client) FaultSummary(ctx context.Context, volumeID int64) string {
+	faults, err := c.ListClusterFaults(ctx, "current", FaultFilter{VolumeID: volumeID, MinSeverity: "warning"})
+	if err != nil {
+		log.Debug("Unable to list cluster faults: ", err)
+		return ""
+	}
+	if volumeID == 0 {
+		var general []ClusterFault
+		for _, f := range faults {
+			if len(volumeIDsIn(f.Data)) == 0 {
+				general = append(general, f)
+			}
+		}
+		faults = general
+	}
+	if len(faults) == 0 {
+		return ""
+	}
+	// Most severe first
+	sort.SliceStable(faults, func(i, j int) bool {
+		return FaultSeverityLevel(faults[i].Severity) > FaultSeverityLevel(faults[j].Severity)
+	})
+	const max = 3
+	var descs []string
+	for i, f := range faults {
+		if i == max {
+			descs = append(descs, fmt.Sprintf("and %d more", len(faults)-max))
+			break
+		}
+		descs = append(descs, fmt.Sprintf("%s %s: %s", f.Severity, f.Code, f.Details))
+	}
+	return "cluster faults: " + strings.Join(descs, "; ")
+}
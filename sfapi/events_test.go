@@ -0,0 +1,111 @@
+package sfapi_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+var (
+	eventTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier   = eventTime.Add(-time.Hour)
+	later     = eventTime.Add(time.Hour)
+)
+
+func TestFaultFilter(t *testing.T) {
+	fault := sfapi.ClusterFault{
+		Severity: "error",
+		Date:     eventTime.Format(time.RFC3339Nano),
+		Data:     map[string]interface{}{"volumeIDs": []interface{}{float64(4), float64(7)}},
+	}
+	general := sfapi.ClusterFault{Severity: "warning", Date: "not a time"}
+
+	for _, tc := range []struct {
+		name   string
+		filter sfapi.FaultFilter
+		fault  sfapi.ClusterFault
+		match  bool
+	}{
+		{"empty filter", sfapi.FaultFilter{}, fault, true},
+		{"since before", sfapi.FaultFilter{Since: earlier}, fault, true},
+		{"since exactly", sfapi.FaultFilter{Since: eventTime}, fault, true},
+		{"since after", sfapi.FaultFilter{Since: later}, fault, false},
+		{"until after", sfapi.FaultFilter{Until: later}, fault, true},
+		{"until exactly", sfapi.FaultFilter{Until: eventTime}, fault, false},
+		{"unparseable date", sfapi.FaultFilter{Since: later}, general, true},
+		{"volume listed", sfapi.FaultFilter{VolumeID: 7}, fault, true},
+		{"volume not listed", sfapi.FaultFilter{VolumeID: 5}, fault, false},
+		{"fault about no volume", sfapi.FaultFilter{VolumeID: 5}, general, true},
+		{"severity below", sfapi.FaultFilter{MinSeverity: "critical"}, fault, false},
+		{"severity equal", sfapi.FaultFilter{MinSeverity: "error"}, fault, true},
+		{"severity above", sfapi.FaultFilter{MinSeverity: "Warning"}, fault, true},
+		{"severity and volume", sfapi.FaultFilter{MinSeverity: "warning", VolumeID: 5}, fault, false},
+	} {
+		if got := tc.filter.Match(tc.fault); got != tc.match {
+			t.Errorf("%s: Match = %t, expected %t", tc.name, got, tc.match)
+		}
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	event := sfapi.Event{
+		Severity:     2,
+		TimeOfReport: eventTime.Format(time.RFC3339Nano),
+		Details:      map[string]interface{}{"volume": map[string]interface{}{"volumeID": float64(4)}},
+	}
+	general := sfapi.Event{Severity: 0, TimeOfReport: eventTime.Format(time.RFC3339Nano)}
+
+	for _, tc := range []struct {
+		name   string
+		filter sfapi.EventFilter
+		event  sfapi.Event
+		match  bool
+	}{
+		{"empty filter", sfapi.EventFilter{}, event, true},
+		{"since before", sfapi.EventFilter{Since: earlier}, event, true},
+		{"since after", sfapi.EventFilter{Since: later}, event, false},
+		{"until", sfapi.EventFilter{Until: eventTime}, event, false},
+		{"nested volume", sfapi.EventFilter{VolumeID: 4}, event, true},
+		{"other volume", sfapi.EventFilter{VolumeID: 5}, event, false},
+		{"event about no volume", sfapi.EventFilter{VolumeID: 4}, general, false},
+		{"severity below", sfapi.EventFilter{MinSeverity: 3}, event, false},
+		{"severity equal", sfapi.EventFilter{MinSeverity: 2}, event, true},
+		{"everything", sfapi.EventFilter{Since: earlier, VolumeID: 4, MinSeverity: 1}, event, true},
+	} {
+		if got := tc.filter.Match(tc.event); got != tc.match {
+			t.Errorf("%s: Match = %t, expected %t", tc.name, got, tc.match)
+		}
+	}
+}
+
+func TestListEventsAfter(t *testing.T) {
+	fc, c, _ := newFakeClient(t)
+	ctx := context.Background()
+	first := fc.AddEvent(sfapi.Event{EventInfoType: "test", Message: "first"})
+	var want []int64
+	for i := 0; i < 7; i++ {
+		want = append(want, fc.AddEvent(sfapi.Event{EventInfoType: "test"}))
+	}
+
+	for _, pageSize := range []int64{0, 2, 3, 7, 100} {
+		events, err := c.ListEventsAfter(ctx, first, pageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != len(want) {
+			t.Fatalf("page size %d: expected %d events, got %d", pageSize, len(want), len(events))
+		}
+		for i, e := range events {
+			if e.EventID != want[i] {
+				t.Fatalf("page size %d: expected events %v oldest first, got %+v", pageSize, want, events)
+			}
+		}
+	}
+
+	events, err := c.ListEventsAfter(ctx, want[len(want)-1], 2)
+	if err != nil || len(events) != 0 {
+		t.Fatalf("expected no events after the last one, got %+v, %v", events, err)
+	}
+}
@@ -0,0 +1,279 @@
+// Package fake provides an in-memory SolidFire cluster that serves the
+// Element json-rpc API over httptest, for exercising sfapi, the daemon and
+// the CLI without a real cluster.
+//
+//	cluster := fake.NewCluster()
+//	defer cluster.Close()
+//	client, err := sfapi.New(cluster.Config())
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+// Fault describes an injected failure.  Faults are applied to matching
+// requests in the order they were injected.
+type Fault struct {
+	Method  string          //method to match, "" matches every method
+	Err     *sfapi.APIError //respond with this json-rpc error
+	Latency time.Duration   //delay before responding (or failing)
+	Drop    bool            //close the connection without responding
+	Apply   bool            //carry out the request before failing, as if only the response was lost
+	Count   int             //number of requests to apply to, 0 for all
+}
+
+// Cluster is the fake cluster, its exported fields may be changed before
+// the first request is issued
+type Cluster struct {
+	Server     *httptest.Server
+	Username   string
+	Password   string
+	APIVersion string //highest API version the cluster accepts
+	SVIP       string
+
+	// AsyncPolls is the number of GetAsyncResult calls that report an
+	// async operation as running before it completes
+	AsyncPolls int
+
+	// Capacity reported by GetClusterCapacity and GetClusterFullThreshold,
+	// the fullness stages are names such as "stage4Critical"
+	TotalBytes          int64
+	MaxProvisionedBytes int64
+	BlockFullness       string
+	MetadataFullness    string
+
+	mu            sync.Mutex
+	nextID        map[string]int64
+	accounts      map[int64]*sfapi.Account
+	volumes       map[int64]*sfapi.Volume
+	snapshots     map[int64]*sfapi.Snapshot
+	groups        map[int64]*sfapi.GroupSnapshot
+	vags          map[int64]*sfapi.VolumeAccessGroup
+	policies      map[int64]*sfapi.QoSPolicy
+	schedules     map[int64]*sfapi.Schedule
+	clusterFaults []sfapi.ClusterFault
+	events        []sfapi.Event
+	async         map[int64]*asyncOp
+	faults        []*Fault
+	calls         map[string]int
+	handlers      map[string]handler
+}
+
+type asyncOp struct {
+	resultType string
+	created    time.Time
+	polls      int
+	result     interface{}
+	err        *sfapi.APIError
+}
+
+type handler func(params json.RawMessage) (interface{}, *sfapi.APIError)
+
+// NewCluster starts a fake cluster with no accounts or volumes
+func NewCluster() *Cluster {
+	c := &Cluster{
+		Username:   "admin",
+		Password:   "admin",
+		APIVersion: sfapi.SupportedAPIVersions[len(sfapi.SupportedAPIVersions)-1],
+		SVIP:       "127.0.0.1:3260",
+		AsyncPolls: 1,
+
+		TotalBytes:          10 << 40,
+		MaxProvisionedBytes: 50 << 40,
+		BlockFullness:       "stage1Happy",
+		MetadataFullness:    "stage1Happy",
+
+		nextID:    map[string]int64{},
+		accounts:  map[int64]*sfapi.Account{},
+		volumes:   map[int64]*sfapi.Volume{},
+		snapshots: map[int64]*sfapi.Snapshot{},
+		groups:    map[int64]*sfapi.GroupSnapshot{},
+		vags:      map[int64]*sfapi.VolumeAccessGroup{},
+		policies:  map[int64]*sfapi.QoSPolicy{},
+		schedules: map[int64]*sfapi.Schedule{},
+		async:     map[int64]*asyncOp{},
+		calls:     map[string]int{},
+	}
+	c.registerHandlers()
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+	return c
+}
+
+// Close shuts down the cluster's server
+func (c *Cluster) Close() {
+	c.Server.Close()
+}
+
+// Endpoint returns the json-rpc endpoint of the cluster for the given API
+// version (the cluster's APIVersion if version is "")
+func (c *Cluster) Endpoint(version string) string {
+	if version == "" {
+		version = c.APIVersion
+	}
+	return c.Server.URL + "/json-rpc/" + version
+}
+
+// Config returns an sfapi.Config pointing at the cluster
+func (c *Cluster) Config() sfapi.Config {
+	return sfapi.Config{
+		EndPoint:     c.Endpoint(sfapi.SupportedAPIVersions[0]),
+		Username:     c.Username,
+		Password:     sfapi.Secret(c.Password),
+		SVIP:         c.SVIP,
+		TenantName:   "docker",
+		DefaultVolSz: 1,
+		Retry:        sfapi.RetryPolicy{MaxAttempts: 1},
+	}
+}
+
+// InjectFault adds a fault to be applied to subsequent requests
+func (c *Cluster) InjectFault(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &f)
+}
+
+// ClearFaults removes all injected faults
+func (c *Cluster) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// Calls returns the number of requests received for method
+func (c *Cluster) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[method]
+}
+
+// takeFault returns the first fault matching method, consuming one of its
+// uses
+func (c *Cluster) takeFault(method string) *Fault {
+	for i, f := range c.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				c.faults = append(c.faults[:i], c.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (c *Cluster) newID(kind string) int64 {
+	c.nextID[kind]++
+	return c.nextID[kind]
+}
+
+func apiError(name, format string, args ...interface{}) *sfapi.APIError {
+	return &sfapi.APIError{Code: 500, Name: name, Message: fmt.Sprintf(format, args...)}
+}
+
+func (c *Cluster) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, _ := r.BasicAuth()
+	if user != c.Username || pass != c.Password {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Method string          `json:"method"`
+		ID     interface{}     `json:"id"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	c.calls[req.Method]++
+	fault := c.takeFault(req.Method)
+	c.mu.Unlock()
+
+	resp := map[string]interface{}{"id": req.ID}
+	var (
+		result  interface{}
+		apiErr  *sfapi.APIError
+		handled bool
+	)
+	if fault != nil {
+		if fault.Latency > 0 {
+			time.Sleep(fault.Latency)
+		}
+		if fault.Apply {
+			result, apiErr = c.handle(r.URL.Path, req.Method, req.Params)
+			handled = true
+		}
+		if fault.Drop {
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			panic(http.ErrAbortHandler)
+		}
+		if fault.Err != nil {
+			resp["error"] = fault.Err
+			writeJSON(w, resp)
+			return
+		}
+	}
+
+	if !handled {
+		result, apiErr = c.handle(r.URL.Path, req.Method, req.Params)
+	}
+	if apiErr != nil {
+		resp["error"] = apiErr
+	} else {
+		resp["result"] = result
+	}
+	writeJSON(w, resp)
+}
+
+// handle carries out a request made to the json-rpc endpoint at path
+func (c *Cluster) handle(path, method string, params json.RawMessage) (interface{}, *sfapi.APIError) {
+	version := strings.TrimPrefix(path, "/json-rpc/")
+	if version == path || sfapi.CompareAPIVersions(version, c.APIVersion) > 0 {
+		return nil, apiError("xUnknownAPIVersion", "API version %s is not supported", version)
+	}
+
+	h, ok := c.handlers[method]
+	if min, versioned := methodVersions[method]; versioned && sfapi.CompareAPIVersions(version, min) < 0 {
+		ok = false
+	}
+	if !ok {
+		return nil, apiError("xUnknownAPIMethod", "Unknown method %s", method)
+	}
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return h(params)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,1494 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+const iqnPrefix = "iqn.2010-01.com.solidfire:fake."
+
+// methodVersions are the API versions that introduced methods newer than
+// 7.0, requests for them using an older version are rejected
+var methodVersions = map[string]string{
+	"CreateQoSPolicy": "10.0",
+	"ListQoSPolicies": "10.0",
+	"ModifyQoSPolicy": "10.0",
+	"DeleteQoSPolicy": "10.0",
+
+	"PurgeDeletedVolumes": "11.0",
+}
+
+func (c *Cluster) registerHandlers() {
+	c.handlers = map[string]handler{
+		"GetClusterVersionInfo":                 c.getClusterVersionInfo,
+		"GetClusterInfo":                        c.getClusterInfo,
+		"GetClusterCapacity":                    c.getClusterCapacity,
+		"GetClusterFullThreshold":               c.getClusterFullThreshold,
+		"ListClusterFaults":                     c.listClusterFaults,
+		"ListEvents":                            c.listEvents,
+		"AddAccount":                            c.addAccount,
+		"GetAccountByName":                      c.getAccountByName,
+		"GetAccountByID":                        c.getAccountByID,
+		"ListAccounts":                          c.listAccounts,
+		"ModifyAccount":                         c.modifyAccount,
+		"RemoveAccount":                         c.removeAccount,
+		"GetAccountEfficiency":                  c.getAccountEfficiency,
+		"ListActiveVolumes":                     c.listActiveVolumes,
+		"ListVolumesForAccount":                 c.listVolumesForAccount,
+		"CreateVolume":                          c.createVolume,
+		"CloneVolume":                           c.cloneVolume,
+		"CopyVolume":                            c.copyVolume,
+		"CloneMultipleVolumes":                  c.cloneMultipleVolumes,
+		"ModifyVolume":                          c.modifyVolume,
+		"DeleteVolume":                          c.deleteVolume,
+		"ListDeletedVolumes":                    c.listDeletedVolumes,
+		"RestoreDeletedVolume":                  c.restoreDeletedVolume,
+		"PurgeDeletedVolume":                    c.purgeDeletedVolume,
+		"PurgeDeletedVolumes":                   c.purgeDeletedVolumes,
+		"CreateSnapshot":                        c.createSnapshot,
+		"ListSnapshots":                         c.listSnapshots,
+		"RollbackToSnapshot":                    c.rollbackToSnapshot,
+		"DeleteSnapshot":                        c.deleteSnapshot,
+		"CreateGroupSnapshot":                   c.createGroupSnapshot,
+		"ListGroupSnapshots":                    c.listGroupSnapshots,
+		"DeleteGroupSnapshot":                   c.deleteGroupSnapshot,
+		"RollbackToGroupSnapshot":               c.rollbackToGroupSnapshot,
+		"CreateVolumeAccessGroup":               c.createVolumeAccessGroup,
+		"ListVolumeAccessGroups":                c.listVolumeAccessGroups,
+		"AddVolumesToVolumeAccessGroup":         c.addVolumesToVolumeAccessGroup,
+		"AddInitiatorsToVolumeAccessGroup":      c.addInitiatorsToVolumeAccessGroup,
+		"RemoveVolumesFromVolumeAccessGroup":    c.removeVolumesFromVolumeAccessGroup,
+		"RemoveInitiatorsFromVolumeAccessGroup": c.removeInitiatorsFromVolumeAccessGroup,
+		"ModifyVolumeAccessGroup":               c.modifyVolumeAccessGroup,
+		"DeleteVolumeAccessGroup":               c.deleteVolumeAccessGroup,
+		"CreateQoSPolicy":                       c.createQoSPolicy,
+		"ListQoSPolicies":                       c.listQoSPolicies,
+		"ModifyQoSPolicy":                       c.modifyQoSPolicy,
+		"DeleteQoSPolicy":                       c.deleteQoSPolicy,
+		"CreateSchedule":                        c.createSchedule,
+		"GetSchedule":                           c.getSchedule,
+		"ListSchedules":                         c.listSchedules,
+		"ModifySchedule":                        c.modifySchedule,
+		"GetAsyncResult":                        c.getAsyncResult,
+		"ListAsyncResults":                      c.listAsyncResults,
+	}
+}
+
+func decode(params json.RawMessage, req interface{}) *sfapi.APIError {
+	if err := json.Unmarshal(params, req); err != nil {
+		return apiError("xInvalidParameter", "Invalid parameters: %v", err)
+	}
+	return nil
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func sortedKeys(m interface{}) []int64 {
+	var keys []int64
+	switch m := m.(type) {
+	case map[int64]*sfapi.Volume:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int64]*sfapi.Snapshot:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int64]*sfapi.VolumeAccessGroup:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int64]*sfapi.Account:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int64]*sfapi.GroupSnapshot:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int64]*sfapi.Schedule:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int64]*sfapi.QoSPolicy:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int64]*asyncOp:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// AddVolume adds a volume directly to the cluster's state, bypassing the
+// API, and returns its ID
+func (c *Cluster) AddVolume(v sfapi.Volume) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addVolume(v)
+}
+
+func (c *Cluster) addVolume(v sfapi.Volume) int64 {
+	v.VolumeID = c.newID("volume")
+	if v.Status == "" {
+		v.Status = "active"
+	}
+	if v.Access == "" {
+		v.Access = "readWrite"
+	}
+	if v.CreateTime == "" {
+		v.CreateTime = now()
+	}
+	if v.BlockSize == 0 {
+		v.BlockSize = 4096
+	}
+	v.Iqn = fmt.Sprintf("%s%s.%d", iqnPrefix, v.Name, v.VolumeID)
+	v.ScsiNAADeviceID = fmt.Sprintf("6f47acc1000000006d6f636b%08x", v.VolumeID)
+	c.volumes[v.VolumeID] = &v
+	if a, ok := c.accounts[v.AccountID]; ok {
+		a.Volumes = append(a.Volumes, v.VolumeID)
+	}
+	return v.VolumeID
+}
+
+// Volumes returns a copy of every volume on the cluster, including deleted
+// ones
+func (c *Cluster) Volumes() []sfapi.Volume {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var vols []sfapi.Volume
+	for _, id := range sortedKeys(c.volumes) {
+		vols = append(vols, *c.volumes[id])
+	}
+	return vols
+}
+
+// Accounts returns a copy of every account on the cluster
+func (c *Cluster) Accounts() []sfapi.Account {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var accts []sfapi.Account
+	for _, id := range sortedKeys(c.accounts) {
+		accts = append(accts, *c.accounts[id])
+	}
+	return accts
+}
+
+// startAsync records a new async operation and returns its handle
+func (c *Cluster) startAsync(resultType string, result interface{}) int64 {
+	id := c.newID("async")
+	c.async[id] = &asyncOp{resultType: resultType, created: time.Now(), result: result}
+	return id
+}
+
+func (c *Cluster) getClusterVersionInfo(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	return map[string]interface{}{
+		"clusterAPIVersion":  c.APIVersion,
+		"clusterVersion":     c.APIVersion + ".0.0",
+		"clusterVersionInfo": []interface{}{},
+	}, nil
+}
+
+func (c *Cluster) getClusterInfo(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	host := strings.TrimPrefix(c.Server.URL, "http://")
+	return map[string]interface{}{
+		"clusterInfo": sfapi.ClusterInfo{
+			Name:     "fake",
+			MVIP:     host,
+			SVIP:     c.SVIP,
+			UniqueID: "fake",
+			UUID:     "00000000-0000-0000-0000-000000000000",
+			RepCount: 2,
+			Ensemble: []string{host},
+		},
+	}, nil
+}
+
+// usage returns the bytes provisioned by the cluster's volumes, and the
+// bytes they'd use if a quarter of their blocks were written
+func (c *Cluster) usage() (provisioned, used int64) {
+	for _, v := range c.volumes {
+		provisioned += v.TotalSize
+	}
+	return provisioned, provisioned / 4
+}
+
+func (c *Cluster) getClusterCapacity(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	provisioned, used := c.usage()
+	blocks := provisioned / 4096
+	return map[string]interface{}{
+		"clusterCapacity": sfapi.ClusterCapacity{
+			MaxProvisionedSpace:   c.MaxProvisionedBytes,
+			MaxUsedSpace:          c.TotalBytes,
+			ProvisionedSpace:      provisioned,
+			UsedSpace:             used,
+			NonZeroBlocks:         blocks / 4,
+			ZeroBlocks:            blocks - blocks/4,
+			UniqueBlocks:          blocks / 8,
+			UniqueBlocksUsedSpace: blocks / 8 * 2048,
+			Timestamp:             now(),
+		},
+	}, nil
+}
+
+func (c *Cluster) getClusterFullThreshold(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	_, used := c.usage()
+	fullness := c.BlockFullness
+	if sfapi.FullnessStage(c.MetadataFullness) > sfapi.FullnessStage(fullness) {
+		fullness = c.MetadataFullness
+	}
+	return sfapi.ClusterFullThreshold{
+		BlockFullness:             c.BlockFullness,
+		MetadataFullness:          c.MetadataFullness,
+		Fullness:                  fullness,
+		Stage3BlockThresholdBytes: c.TotalBytes / 100 * 73,
+		Stage4BlockThresholdBytes: c.TotalBytes / 100 * 87,
+		Stage5BlockThresholdBytes: c.TotalBytes,
+		SumTotalClusterBytes:      c.TotalBytes,
+		SumUsedClusterBytes:       used,
+	}, nil
+}
+
+// AddClusterFault raises a fault on the cluster, returning its ID.  The
+// fault's Date defaults to now.
+func (c *Cluster) AddClusterFault(f sfapi.ClusterFault) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f.ClusterFaultID = c.newID("fault")
+	if f.Date == "" {
+		f.Date = now()
+	}
+	c.clusterFaults = append(c.clusterFaults, f)
+	return f.ClusterFaultID
+}
+
+// ResolveClusterFault marks a fault raised by AddClusterFault resolved
+func (c *Cluster) ResolveClusterFault(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.clusterFaults {
+		if c.clusterFaults[i].ClusterFaultID == id {
+			c.clusterFaults[i].Resolved = true
+			c.clusterFaults[i].ResolvedDate = now()
+		}
+	}
+}
+
+// AddEvent adds an event to the cluster's event log, returning its ID.
+// Volume creates and deletes are logged as well.
+func (c *Cluster) AddEvent(e sfapi.Event) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addEvent(e)
+}
+
+func (c *Cluster) addEvent(e sfapi.Event) int64 {
+	e.EventID = c.newID("event")
+	if e.TimeOfReport == "" {
+		e.TimeOfReport = now()
+	}
+	if e.TimeOfPublish == "" {
+		e.TimeOfPublish = e.TimeOfReport
+	}
+	c.events = append(c.events, e)
+	return e.EventID
+}
+
+func (c *Cluster) listClusterFaults(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListClusterFaultsRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	faults := []sfapi.ClusterFault{}
+	for _, f := range c.clusterFaults {
+		switch {
+		case f.Severity == "bestPractice" && !req.BestPractices:
+		case req.FaultTypes == "current" && f.Resolved:
+		case req.FaultTypes == "resolved" && !f.Resolved:
+		default:
+			faults = append(faults, f)
+		}
+	}
+	return map[string]interface{}{"faults": faults}, nil
+}
+
+// listEvents returns the most recent events first, like the cluster
+func (c *Cluster) listEvents(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListEventsRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	events := []sfapi.Event{}
+	for i := len(c.events) - 1; i >= 0; i-- {
+		e := c.events[i]
+		if e.EventID < req.StartEventID || (req.EndEventID > 0 && e.EventID > req.EndEventID) {
+			continue
+		}
+		if req.EventType != "" && e.EventInfoType != req.EventType {
+			continue
+		}
+		if req.MaxEvents > 0 && int64(len(events)) >= req.MaxEvents {
+			break
+		}
+		events = append(events, e)
+	}
+	return map[string]interface{}{"events": events}, nil
+}
+
+func (c *Cluster) addAccount(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.AddAccountRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	for _, a := range c.accounts {
+		if a.Username == req.Username {
+			return nil, apiError("xDuplicateUsername", "Username %s already exists", req.Username)
+		}
+	}
+	a := &sfapi.Account{
+		AccountID:       c.newID("account"),
+		Username:        req.Username,
+		Status:          "active",
+		InitiatorSecret: req.InitiatorSecret,
+		TargetSecret:    req.TargetSecret,
+		Attributes:      req.Attributes,
+	}
+	if a.InitiatorSecret == "" {
+		a.InitiatorSecret = sfapi.Secret(fmt.Sprintf("initsecret%04d", a.AccountID))
+	}
+	if a.TargetSecret == "" {
+		a.TargetSecret = sfapi.Secret(fmt.Sprintf("tgtsecret%04d", a.AccountID))
+	}
+	c.accounts[a.AccountID] = a
+	return map[string]interface{}{"accountID": a.AccountID}, nil
+}
+
+func (c *Cluster) getAccountByName(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.GetAccountByNameRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	for _, a := range c.accounts {
+		if a.Username == req.Name {
+			return map[string]interface{}{"account": a}, nil
+		}
+	}
+	return nil, apiError("xUnknownAccount", "Unknown account %s", req.Name)
+}
+
+func (c *Cluster) account(id int64) (*sfapi.Account, *sfapi.APIError) {
+	a, ok := c.accounts[id]
+	if !ok {
+		return nil, apiError("xUnknownAccount", "Unknown account %d", id)
+	}
+	return a, nil
+}
+
+func (c *Cluster) getAccountByID(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.GetAccountByIDRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	a, err := c.account(req.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"account": a}, nil
+}
+
+func (c *Cluster) listAccounts(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListAccountsRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	accounts := []sfapi.Account{}
+	for _, id := range sortedKeys(c.accounts) {
+		if id < req.StartAccountID {
+			continue
+		}
+		if req.Limit > 0 && int64(len(accounts)) >= req.Limit {
+			break
+		}
+		accounts = append(accounts, *c.accounts[id])
+	}
+	return map[string]interface{}{"accounts": accounts}, nil
+}
+
+func (c *Cluster) modifyAccount(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ModifyAccountRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	a, err := c.account(req.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != "" && req.Status != "active" && req.Status != "locked" {
+		return nil, apiError("xInvalidParameter", "Invalid status %q", req.Status)
+	}
+	if req.Username != "" {
+		for _, other := range c.accounts {
+			if other.Username == req.Username && other.AccountID != a.AccountID {
+				return nil, apiError("xDuplicateUsername", "Username %s already exists", req.Username)
+			}
+		}
+		a.Username = req.Username
+	}
+	if req.Status != "" {
+		a.Status = req.Status
+	}
+	if req.InitiatorSecret != "" {
+		a.InitiatorSecret = req.InitiatorSecret
+	}
+	if req.TargetSecret != "" {
+		a.TargetSecret = req.TargetSecret
+	}
+	if req.Attributes != nil {
+		a.Attributes = req.Attributes
+	}
+	return map[string]interface{}{"account": a}, nil
+}
+
+func (c *Cluster) removeAccount(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.RemoveAccountRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	a, err := c.account(req.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Volumes) > 0 {
+		return nil, apiError("xAccountHasVolumes", "Account %d still has volumes %v", a.AccountID, a.Volumes)
+	}
+	delete(c.accounts, a.AccountID)
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) getAccountEfficiency(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.GetAccountEfficiencyRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if _, err := c.account(req.AccountID); err != nil {
+		return nil, err
+	}
+	return sfapi.AccountEfficiency{
+		Compression:      1.5,
+		Deduplication:    1.2,
+		ThinProvisioning: 2,
+		MissingVolumes:   []int64{},
+		Timestamp:        now(),
+	}, nil
+}
+
+// listVolumes returns the volumes accepted by match in ID order, starting
+// at startID and returning at most limit (if not 0)
+func (c *Cluster) listVolumes(startID, limit int64, match func(*sfapi.Volume) bool) interface{} {
+	vols := []sfapi.Volume{}
+	for _, id := range sortedKeys(c.volumes) {
+		v := c.volumes[id]
+		if id < startID || !match(v) {
+			continue
+		}
+		if limit > 0 && int64(len(vols)) >= limit {
+			break
+		}
+		vols = append(vols, *v)
+	}
+	return map[string]interface{}{"volumes": vols}
+}
+
+func (c *Cluster) listActiveVolumes(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListActiveVolumesRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	return c.listVolumes(req.StartVolumeID, req.Limit, func(v *sfapi.Volume) bool {
+		return v.Status == "active"
+	}), nil
+}
+
+func (c *Cluster) listVolumesForAccount(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListVolumesForAccountRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if _, ok := c.accounts[req.AccountID]; !ok {
+		return nil, apiError("xUnknownAccount", "Unknown account %d", req.AccountID)
+	}
+	return c.listVolumes(req.StartVolumeID, req.Limit, func(v *sfapi.Volume) bool {
+		return v.AccountID == req.AccountID
+	}), nil
+}
+
+func (c *Cluster) activeVolume(id int64) (*sfapi.Volume, *sfapi.APIError) {
+	v, ok := c.volumes[id]
+	if !ok || v.Status != "active" {
+		return nil, apiError("xVolumeIDDoesNotExist", "VolumeID %d does not exist", id)
+	}
+	return v, nil
+}
+
+func (c *Cluster) createVolume(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CreateVolumeRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if _, ok := c.accounts[req.AccountID]; !ok {
+		return nil, apiError("xUnknownAccount", "Unknown account %d", req.AccountID)
+	}
+	if req.TotalSize <= 0 {
+		return nil, apiError("xInvalidParameter", "Invalid totalSize %d", req.TotalSize)
+	}
+	v := sfapi.Volume{
+		Name:       req.Name,
+		AccountID:  req.AccountID,
+		TotalSize:  req.TotalSize,
+		Enable512e: req.Enable512e,
+		Attributes: req.Attributes,
+	}
+	if req.Qos != nil {
+		v.Qos = *req.Qos
+	}
+	if req.QoSPolicyID != 0 {
+		p, err := c.policy(req.QoSPolicyID)
+		if err != nil {
+			return nil, err
+		}
+		v.QoSPolicyID = p.QoSPolicyID
+		v.Qos = p.Qos
+	}
+	id := c.addVolume(v)
+	c.addEvent(sfapi.Event{EventInfoType: "apiEvent", Message: "API Call (CreateVolume)",
+		Details: map[string]interface{}{"volumeID": id}})
+	return map[string]interface{}{"volumeID": id, "volume": c.volumes[id]}, nil
+}
+
+func (c *Cluster) cloneVolume(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CloneVolumeRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	src, err := c.activeVolume(req.VolumeID)
+	if err != nil {
+		return nil, err
+	}
+	clone := *src
+	clone.Name = req.Name
+	clone.VolumeAccessGroups = nil
+	clone.CreateTime = ""
+	if req.NewAccountID != 0 {
+		clone.AccountID = req.NewAccountID
+	}
+	if req.NewSize != 0 {
+		clone.TotalSize = req.NewSize
+	}
+	if req.Access != "" {
+		clone.Access = req.Access
+	}
+	if req.Attributes != nil {
+		clone.Attributes = req.Attributes
+	}
+	id := c.addVolume(clone)
+	handle := c.startAsync("Clone", map[string]interface{}{"volumeID": id})
+	return map[string]interface{}{"cloneID": id, "volumeID": id, "asyncHandle": handle}, nil
+}
+
+func (c *Cluster) copyVolume(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CopyVolumeRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if _, err := c.activeVolume(req.VolumeID); err != nil {
+		return nil, err
+	}
+	if _, err := c.activeVolume(req.DstVolumeID); err != nil {
+		return nil, err
+	}
+	cloneID := c.newID("clone")
+	handle := c.startAsync("Clone", map[string]interface{}{"volumeID": req.DstVolumeID})
+	return map[string]interface{}{"cloneID": cloneID, "asyncHandle": handle}, nil
+}
+
+func (c *Cluster) cloneMultipleVolumes(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CloneMultipleVolumesRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	for _, p := range req.Volumes {
+		if _, err := c.activeVolume(p.VolumeID); err != nil {
+			return nil, err
+		}
+	}
+	var members []map[string]int64
+	for _, p := range req.Volumes {
+		clone := *c.volumes[p.VolumeID]
+		clone.Name = p.Name
+		clone.VolumeAccessGroups = nil
+		clone.CreateTime = ""
+		if clone.Name == "" {
+			clone.Name = fmt.Sprintf("%s-clone", c.volumes[p.VolumeID].Name)
+		}
+		if acct := p.NewAccountID; acct != 0 {
+			clone.AccountID = acct
+		} else if req.NewAccountID != 0 {
+			clone.AccountID = req.NewAccountID
+		}
+		if p.NewSize != 0 {
+			clone.TotalSize = p.NewSize
+		}
+		if p.Access != "" {
+			clone.Access = p.Access
+		} else if req.Access != "" {
+			clone.Access = req.Access
+		}
+		id := c.addVolume(clone)
+		members = append(members, map[string]int64{"volumeID": id, "srcVolumeID": p.VolumeID})
+	}
+	groupCloneID := c.newID("groupClone")
+	handle := c.startAsync("CloneMultiple", map[string]interface{}{"groupCloneID": groupCloneID})
+	return map[string]interface{}{"asyncHandle": handle, "groupCloneID": groupCloneID, "members": members}, nil
+}
+
+func (c *Cluster) modifyVolume(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ModifyVolumeRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	v, err := c.activeVolume(req.VolumeID)
+	if err != nil {
+		return nil, err
+	}
+	if req.TotalSize != 0 && req.TotalSize < v.TotalSize {
+		return nil, apiError("xVolumeShrinkProhibited", "Volume %d can't be shrunk from %d to %d bytes", v.VolumeID, v.TotalSize, req.TotalSize)
+	}
+	if req.AccountID != 0 && req.AccountID != v.AccountID {
+		a, ok := c.accounts[req.AccountID]
+		if !ok {
+			return nil, apiError("xUnknownAccount", "Unknown account %d", req.AccountID)
+		}
+		if old, ok := c.accounts[v.AccountID]; ok {
+			old.Volumes = removeID(old.Volumes, v.VolumeID)
+		}
+		a.Volumes = append(a.Volumes, v.VolumeID)
+		v.AccountID = req.AccountID
+	}
+	if req.TotalSize != 0 {
+		v.TotalSize = req.TotalSize
+	}
+	if req.Access != "" {
+		v.Access = req.Access
+	}
+	if req.QoSPolicyID != 0 {
+		p, err := c.policy(req.QoSPolicyID)
+		if err != nil {
+			return nil, err
+		}
+		v.QoSPolicyID = p.QoSPolicyID
+		v.Qos = p.Qos
+	}
+	if req.AssociateWithQoSPolicy != nil && !*req.AssociateWithQoSPolicy {
+		v.QoSPolicyID = 0
+	}
+	if req.Qos != nil {
+		if v.QoSPolicyID != 0 {
+			return nil, apiError("xInvalidParameter", "Volume %d uses QoS policy %d, its QoS can't be set directly", v.VolumeID, v.QoSPolicyID)
+		}
+		v.Qos = *req.Qos
+	}
+	if req.Attributes != nil {
+		v.Attributes = req.Attributes
+	}
+	return map[string]interface{}{"volume": v}, nil
+}
+
+func (c *Cluster) deleteVolume(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.DeleteVolumeRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	v, err := c.activeVolume(req.VolumeID)
+	if err != nil {
+		return nil, err
+	}
+	v.Status = "deleted"
+	v.DeleteTime = now()
+	v.PurgeTime = time.Now().UTC().Add(8 * time.Hour).Format(time.RFC3339)
+	c.addEvent(sfapi.Event{EventInfoType: "apiEvent", Message: "API Call (DeleteVolume)",
+		Details: map[string]interface{}{"volumeID": v.VolumeID}})
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) deletedVolume(id int64) (*sfapi.Volume, *sfapi.APIError) {
+	v, ok := c.volumes[id]
+	if !ok || v.Status != "deleted" {
+		return nil, apiError("xVolumeIDDoesNotExist", "Deleted VolumeID %d does not exist", id)
+	}
+	return v, nil
+}
+
+func (c *Cluster) listDeletedVolumes(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	return c.listVolumes(0, 0, func(v *sfapi.Volume) bool {
+		return v.Status == "deleted"
+	}), nil
+}
+
+func (c *Cluster) restoreDeletedVolume(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.RestoreDeletedVolumeRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	v, err := c.deletedVolume(req.VolumeID)
+	if err != nil {
+		return nil, err
+	}
+	v.Status = "active"
+	v.DeleteTime = ""
+	v.PurgeTime = ""
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) purgeDeletedVolume(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.PurgeDeletedVolumeRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if _, err := c.deletedVolume(req.VolumeID); err != nil {
+		return nil, err
+	}
+	c.purge(req.VolumeID)
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) purgeDeletedVolumes(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.PurgeDeletedVolumesRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	ids := req.VolumeIDs
+	for _, id := range sortedKeys(c.volumes) {
+		if containsID(req.AccountIDs, c.volumes[id].AccountID) && c.volumes[id].Status == "deleted" {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		if _, err := c.deletedVolume(id); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range ids {
+		c.purge(id)
+	}
+	return map[string]interface{}{}, nil
+}
+
+// purge removes a volume along with its snapshots
+func (c *Cluster) purge(id int64) {
+	if a, ok := c.accounts[c.volumes[id].AccountID]; ok {
+		var vols []int64
+		for _, v := range a.Volumes {
+			if v != id {
+				vols = append(vols, v)
+			}
+		}
+		a.Volumes = vols
+	}
+	delete(c.volumes, id)
+	for sid, s := range c.snapshots {
+		if s.VolumeID == id {
+			delete(c.snapshots, sid)
+		}
+	}
+}
+
+func (c *Cluster) createSnapshot(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CreateSnapshotRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	v, err := c.activeVolume(req.VolumeID)
+	if err != nil {
+		return nil, err
+	}
+	s := &sfapi.Snapshot{
+		SnapshotID: c.newID("snapshot"),
+		VolumeID:   req.VolumeID,
+		Name:       req.Name,
+		Status:     "done",
+		TotalSize:  v.TotalSize,
+		CreateTime: now(),
+		Attributes: req.Attributes,
+	}
+	if s.Name == "" {
+		s.Name = s.CreateTime
+	}
+	s.Checksum = fmt.Sprintf("0x%08x", s.SnapshotID)
+	c.snapshots[s.SnapshotID] = s
+	return map[string]interface{}{"snapshotID": s.SnapshotID, "checksum": s.Checksum}, nil
+}
+
+func (c *Cluster) listSnapshots(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListSnapshotsRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	snaps := []sfapi.Snapshot{}
+	for _, id := range sortedKeys(c.snapshots) {
+		s := c.snapshots[id]
+		if req.VolumeID == 0 || s.VolumeID == req.VolumeID {
+			snaps = append(snaps, *s)
+		}
+	}
+	return map[string]interface{}{"snapshots": snaps}, nil
+}
+
+func (c *Cluster) rollbackToSnapshot(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.RollbackToSnapshotRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if _, err := c.activeVolume(req.VolumeID); err != nil {
+		return nil, err
+	}
+	s, ok := c.snapshots[req.SnapshotID]
+	if !ok || s.VolumeID != req.VolumeID {
+		return nil, apiError("xSnapshotIDDoesNotExist", "SnapshotID %d does not exist", req.SnapshotID)
+	}
+	result := map[string]interface{}{"checksum": s.Checksum}
+	if req.SaveCurrentState {
+		raw, _ := json.Marshal(sfapi.CreateSnapshotRequest{VolumeID: req.VolumeID, Name: req.Name, Attributes: req.Attributes})
+		saved, err := c.createSnapshot(raw)
+		if err != nil {
+			return nil, err
+		}
+		result["snapshotID"] = saved.(map[string]interface{})["snapshotID"]
+	}
+	return result, nil
+}
+
+func (c *Cluster) deleteSnapshot(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.DeleteSnapshotRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if _, ok := c.snapshots[req.SnapshotID]; !ok {
+		return nil, apiError("xSnapshotIDDoesNotExist", "SnapshotID %d does not exist", req.SnapshotID)
+	}
+	delete(c.snapshots, req.SnapshotID)
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) createGroupSnapshot(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CreateGroupSnapshotRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Volumes) == 0 {
+		return nil, apiError("xInvalidParameter", "At least one volume is required")
+	}
+	for _, id := range req.Volumes {
+		if _, err := c.activeVolume(id); err != nil {
+			return nil, err
+		}
+	}
+	g := &sfapi.GroupSnapshot{
+		GroupSnapshotID: c.newID("groupSnapshot"),
+		Name:            req.Name,
+		Status:          "done",
+		CreateTime:      now(),
+		Attributes:      req.Attributes,
+	}
+	g.GroupSnapshotUUID = fmt.Sprintf("00000000-0000-0000-0000-%012x", g.GroupSnapshotID)
+	if g.Name == "" {
+		g.Name = g.CreateTime
+	}
+	for _, id := range req.Volumes {
+		s := &sfapi.Snapshot{
+			SnapshotID: c.newID("snapshot"),
+			VolumeID:   id,
+			Name:       g.Name,
+			Status:     "done",
+			TotalSize:  c.volumes[id].TotalSize,
+			GroupID:    g.GroupSnapshotID,
+			CreateTime: g.CreateTime,
+		}
+		s.Checksum = fmt.Sprintf("0x%08x", s.SnapshotID)
+		c.snapshots[s.SnapshotID] = s
+		g.Members = append(g.Members, sfapi.GroupSnapshotMember{
+			VolumeID:     id,
+			SnapshotID:   s.SnapshotID,
+			SnapshotUUID: fmt.Sprintf("00000000-0000-0000-0001-%012x", s.SnapshotID),
+			Checksum:     s.Checksum,
+		})
+	}
+	c.groups[g.GroupSnapshotID] = g
+	return map[string]interface{}{
+		"groupSnapshotID":   g.GroupSnapshotID,
+		"groupSnapshotUUID": g.GroupSnapshotUUID,
+		"members":           g.Members,
+	}, nil
+}
+
+func (c *Cluster) listGroupSnapshots(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListGroupSnapshotsRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	groups := []sfapi.GroupSnapshot{}
+	for _, id := range sortedKeys(c.groups) {
+		g := c.groups[id]
+		if req.GroupSnapshotID != 0 && id != req.GroupSnapshotID {
+			continue
+		}
+		match := len(req.Volumes) == 0
+		for _, m := range g.Members {
+			if containsID(req.Volumes, m.VolumeID) {
+				match = true
+			}
+		}
+		if match {
+			groups = append(groups, *g)
+		}
+	}
+	return map[string]interface{}{"groupSnapshots": groups}, nil
+}
+
+func (c *Cluster) groupSnapshot(id int64) (*sfapi.GroupSnapshot, *sfapi.APIError) {
+	g, ok := c.groups[id]
+	if !ok {
+		return nil, apiError("xGroupSnapshotIDDoesNotExist", "GroupSnapshotID %d does not exist", id)
+	}
+	return g, nil
+}
+
+func (c *Cluster) deleteGroupSnapshot(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.DeleteGroupSnapshotRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.groupSnapshot(req.GroupSnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range g.Members {
+		if req.SaveMembers {
+			if s, ok := c.snapshots[m.SnapshotID]; ok {
+				s.GroupID = 0
+			}
+			continue
+		}
+		delete(c.snapshots, m.SnapshotID)
+	}
+	delete(c.groups, g.GroupSnapshotID)
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) rollbackToGroupSnapshot(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.RollbackToGroupSnapshotRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.groupSnapshot(req.GroupSnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	var vols []int64
+	for _, m := range g.Members {
+		if _, err := c.activeVolume(m.VolumeID); err != nil {
+			return nil, err
+		}
+		vols = append(vols, m.VolumeID)
+	}
+	if !req.SaveCurrentState {
+		return map[string]interface{}{}, nil
+	}
+	raw, _ := json.Marshal(sfapi.CreateGroupSnapshotRequest{Volumes: vols, Name: req.Name, Attributes: req.Attributes})
+	return c.createGroupSnapshot(raw)
+}
+
+func (c *Cluster) vag(id int64) (*sfapi.VolumeAccessGroup, *sfapi.APIError) {
+	g, ok := c.vags[id]
+	if !ok {
+		return nil, apiError("xVolumeAccessGroupIDDoesNotExist", "VolumeAccessGroupID %d does not exist", id)
+	}
+	return g, nil
+}
+
+// addVolumesToVAG adds the volumes to g, skipping any already in it
+func (c *Cluster) addVolumesToVAG(g *sfapi.VolumeAccessGroup, ids []int64) *sfapi.APIError {
+	for _, id := range ids {
+		if _, err := c.activeVolume(id); err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		if containsID(g.Volumes, id) {
+			continue
+		}
+		g.Volumes = append(g.Volumes, id)
+		v := c.volumes[id]
+		v.VolumeAccessGroups = append(v.VolumeAccessGroups, g.VAGID)
+	}
+	return nil
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cluster) createVolumeAccessGroup(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CreateVolumeAccessGroupRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	for _, g := range c.vags {
+		if g.Name == req.Name {
+			return nil, apiError("xDuplicateVolumeAccessGroupName", "Volume access group %s already exists", req.Name)
+		}
+	}
+	g := &sfapi.VolumeAccessGroup{
+		VAGID:          c.newID("vag"),
+		Name:           req.Name,
+		Initiators:     append([]string{}, req.Initiators...),
+		Volumes:        []int64{},
+		DeletedVolumes: []int64{},
+	}
+	if err := c.addVolumesToVAG(g, req.Volumes); err != nil {
+		return nil, err
+	}
+	c.vags[g.VAGID] = g
+	return map[string]interface{}{"volumeAccessGroupID": g.VAGID}, nil
+}
+
+func (c *Cluster) listVolumeAccessGroups(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListVolumeAccessGroupsRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	vags := []sfapi.VolumeAccessGroup{}
+	for _, id := range sortedKeys(c.vags) {
+		if id < req.StartVAGID {
+			continue
+		}
+		if req.Limit > 0 && int64(len(vags)) >= req.Limit {
+			break
+		}
+		vags = append(vags, *c.vags[id])
+	}
+	return map[string]interface{}{"volumeAccessGroups": vags}, nil
+}
+
+func (c *Cluster) addVolumesToVolumeAccessGroup(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.AddVolumesToVolumeAccessGroupRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.vag(req.VolumeAccessGroupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.addVolumesToVAG(g, req.Volumes); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"volumeAccessGroup": g}, nil
+}
+
+func (c *Cluster) addInitiatorsToVolumeAccessGroup(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.AddInitiatorsToVolumeAccessGroupRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.vag(req.VAGID)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range req.Initiators {
+		for _, other := range c.vags {
+			for _, existing := range other.Initiators {
+				if existing == i {
+					return nil, apiError("xDuplicateInitiator", "Initiator %s is already in volume access group %d", i, other.VAGID)
+				}
+			}
+		}
+	}
+	g.Initiators = append(g.Initiators, req.Initiators...)
+	return map[string]interface{}{"volumeAccessGroup": g}, nil
+}
+
+// removeID returns ids without id
+func removeID(ids []int64, id int64) []int64 {
+	out := []int64{}
+	for _, i := range ids {
+		if i != id {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (c *Cluster) removeVolumesFromVolumeAccessGroup(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.RemoveVolumesFromVolumeAccessGroupRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.vag(req.VolumeAccessGroupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range req.Volumes {
+		if !containsID(g.Volumes, id) {
+			return nil, apiError("xVolumeIDDoesNotExist", "VolumeID %d is not in volume access group %d", id, g.VAGID)
+		}
+	}
+	for _, id := range req.Volumes {
+		g.Volumes = removeID(g.Volumes, id)
+		if v, ok := c.volumes[id]; ok {
+			v.VolumeAccessGroups = removeID(v.VolumeAccessGroups, g.VAGID)
+		}
+	}
+	return map[string]interface{}{"volumeAccessGroup": g}, nil
+}
+
+func (c *Cluster) removeInitiatorsFromVolumeAccessGroup(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.RemoveInitiatorsFromVolumeAccessGroupRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.vag(req.VAGID)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range req.Initiators {
+		if !containsString(g.Initiators, i) {
+			return nil, apiError("xInitiatorDoesNotExist", "Initiator %s is not in volume access group %d", i, g.VAGID)
+		}
+	}
+	initiators := []string{}
+	for _, i := range g.Initiators {
+		if !containsString(req.Initiators, i) {
+			initiators = append(initiators, i)
+		}
+	}
+	g.Initiators = initiators
+	return map[string]interface{}{"volumeAccessGroup": g}, nil
+}
+
+func (c *Cluster) modifyVolumeAccessGroup(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ModifyVolumeAccessGroupRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.vag(req.VAGID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != "" {
+		for _, other := range c.vags {
+			if other.Name == req.Name && other.VAGID != g.VAGID {
+				return nil, apiError("xDuplicateVolumeAccessGroupName", "Volume access group %s already exists", req.Name)
+			}
+		}
+		g.Name = req.Name
+	}
+	if req.Attributes != nil {
+		g.Attributes = req.Attributes
+	}
+	return map[string]interface{}{"volumeAccessGroup": g}, nil
+}
+
+func (c *Cluster) deleteVolumeAccessGroup(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.DeleteVolumeAccessGroupRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	g, err := c.vag(req.VAGID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range g.Volumes {
+		if v, ok := c.volumes[id]; ok {
+			v.VolumeAccessGroups = removeID(v.VolumeAccessGroups, g.VAGID)
+		}
+	}
+	delete(c.vags, g.VAGID)
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) policy(id int64) (*sfapi.QoSPolicy, *sfapi.APIError) {
+	p, ok := c.policies[id]
+	if !ok {
+		return nil, apiError("xQoSPolicyDoesNotExist", "QoSPolicyID %d does not exist", id)
+	}
+	return p, nil
+}
+
+// policyResult returns p with the volumes currently using it
+func (c *Cluster) policyResult(p *sfapi.QoSPolicy) sfapi.QoSPolicy {
+	r := *p
+	r.VolumeIDs = []int64{}
+	for _, id := range sortedKeys(c.volumes) {
+		if v := c.volumes[id]; v.QoSPolicyID == p.QoSPolicyID && v.Status == "active" {
+			r.VolumeIDs = append(r.VolumeIDs, id)
+		}
+	}
+	return r
+}
+
+func (c *Cluster) createQoSPolicy(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.CreateQoSPolicyRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	for _, p := range c.policies {
+		if p.Name == req.Name {
+			return nil, apiError("xDuplicateQoSPolicyName", "QoS policy %s already exists", req.Name)
+		}
+	}
+	p := &sfapi.QoSPolicy{QoSPolicyID: c.newID("qosPolicy"), Name: req.Name, Qos: req.Qos}
+	c.policies[p.QoSPolicyID] = p
+	return map[string]interface{}{"qosPolicy": c.policyResult(p)}, nil
+}
+
+func (c *Cluster) listQoSPolicies(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	policies := []sfapi.QoSPolicy{}
+	for _, id := range sortedKeys(c.policies) {
+		policies = append(policies, c.policyResult(c.policies[id]))
+	}
+	return map[string]interface{}{"qosPolicies": policies}, nil
+}
+
+func (c *Cluster) modifyQoSPolicy(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ModifyQoSPolicyRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	p, err := c.policy(req.QoSPolicyID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != "" {
+		p.Name = req.Name
+	}
+	if req.Qos != nil {
+		p.Qos = *req.Qos
+		for _, v := range c.volumes {
+			if v.QoSPolicyID == p.QoSPolicyID {
+				v.Qos = p.Qos
+			}
+		}
+	}
+	return map[string]interface{}{"qosPolicy": c.policyResult(p)}, nil
+}
+
+func (c *Cluster) deleteQoSPolicy(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.DeleteQoSPolicyRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	p, err := c.policy(req.QoSPolicyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.policyResult(p).VolumeIDs) > 0 {
+		return nil, apiError("xQoSPolicyInUse", "QoS policy %d is in use", p.QoSPolicyID)
+	}
+	delete(c.policies, p.QoSPolicyID)
+	return map[string]interface{}{}, nil
+}
+
+func (c *Cluster) validateSchedule(s *sfapi.Schedule) *sfapi.APIError {
+	if s.Name == "" {
+		return apiError("xInvalidParameter", "scheduleName is required")
+	}
+	if s.Type != "Snapshot" {
+		return apiError("xInvalidParameter", "Unsupported scheduleType %q", s.Type)
+	}
+	switch s.Attributes["frequency"] {
+	case sfapi.FrequencyTimeInterval:
+		if s.Hours == 0 && s.Minutes == 0 {
+			return apiError("xInvalidParameter", "Time Interval schedules need hours or minutes")
+		}
+	case sfapi.FrequencyDaysOfWeek:
+		if len(s.Weekdays) == 0 {
+			return apiError("xInvalidParameter", "Days Of Week schedules need weekdays")
+		}
+	case sfapi.FrequencyDaysOfMonth:
+		if len(s.Monthdays) == 0 {
+			return apiError("xInvalidParameter", "Days Of Month schedules need monthdays")
+		}
+	default:
+		return apiError("xInvalidParameter", "Unknown frequency %v", s.Attributes["frequency"])
+	}
+	for _, id := range s.ScheduleInfo.VolumeIDs {
+		if _, err := c.activeVolume(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) createSchedule(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.Schedule
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if err := c.validateSchedule(&req); err != nil {
+		return nil, err
+	}
+	req.ScheduleID = c.newID("schedule")
+	req.LastRunStatus = "Success"
+	c.schedules[req.ScheduleID] = &req
+	return map[string]interface{}{"scheduleID": req.ScheduleID}, nil
+}
+
+func (c *Cluster) schedule(id int64) (*sfapi.Schedule, *sfapi.APIError) {
+	s, ok := c.schedules[id]
+	if !ok {
+		return nil, apiError("xScheduleDoesNotExist", "ScheduleID %d does not exist", id)
+	}
+	return s, nil
+}
+
+func (c *Cluster) getSchedule(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.GetScheduleRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	s, err := c.schedule(req.ScheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"schedule": s}, nil
+}
+
+func (c *Cluster) listSchedules(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	schedules := []sfapi.Schedule{}
+	for _, id := range sortedKeys(c.schedules) {
+		schedules = append(schedules, *c.schedules[id])
+	}
+	return map[string]interface{}{"schedules": schedules}, nil
+}
+
+func (c *Cluster) modifySchedule(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.Schedule
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	existing, err := c.schedule(req.ScheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validateSchedule(&req); err != nil {
+		return nil, err
+	}
+	req.LastRunStatus = existing.LastRunStatus
+	req.LastRunTimeStarted = existing.LastRunTimeStarted
+	if req.ToBeDeleted {
+		delete(c.schedules, req.ScheduleID)
+	} else {
+		c.schedules[req.ScheduleID] = &req
+	}
+	return map[string]interface{}{"schedule": req}, nil
+}
+
+// asyncResult returns the status of op, advancing it by one poll
+func (c *Cluster) asyncResult(op *asyncOp, poll bool) sfapi.AsyncResult {
+	if poll && op.polls <= c.AsyncPolls {
+		op.polls++
+	}
+	r := sfapi.AsyncResult{
+		ResultType:     op.resultType,
+		CreateTime:     op.created.UTC().Format(time.RFC3339),
+		LastUpdateTime: now(),
+		Details:        map[string]interface{}{},
+	}
+	if op.polls <= c.AsyncPolls {
+		r.Status = "running"
+		r.Details["percentComplete"] = op.polls * 100 / (c.AsyncPolls + 1)
+		return r
+	}
+	r.Status = "complete"
+	if op.err != nil {
+		r.Error = op.err
+		return r
+	}
+	r.Details["percentComplete"] = 100
+	r.Result, _ = json.Marshal(op.result)
+	return r
+}
+
+// FailAsync makes the async operation with the given handle finish with
+// err instead of succeeding
+func (c *Cluster) FailAsync(handle int64, err *sfapi.APIError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if op, ok := c.async[handle]; ok {
+		op.err = err
+	}
+}
+
+func (c *Cluster) getAsyncResult(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.GetAsyncResultRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	op, ok := c.async[req.AsyncHandle]
+	if !ok {
+		return nil, apiError("xInvalidParameter", "Async handle %d does not exist", req.AsyncHandle)
+	}
+	r := c.asyncResult(op, true)
+	if r.Status == "complete" && !req.KeepResult {
+		delete(c.async, req.AsyncHandle)
+	}
+	return r, nil
+}
+
+func (c *Cluster) listAsyncResults(params json.RawMessage) (interface{}, *sfapi.APIError) {
+	var req sfapi.ListAsyncResultsRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	handles := []sfapi.AsyncHandle{}
+	for _, id := range sortedKeys(c.async) {
+		op := c.async[id]
+		if len(req.AsyncResultTypes) > 0 && !containsString(req.AsyncResultTypes, op.resultType) {
+			continue
+		}
+		r := c.asyncResult(op, false)
+		handles = append(handles, sfapi.AsyncHandle{
+			AsyncResultID:  id,
+			Completed:      r.Status == "complete",
+			Success:        r.Status == "complete" && r.Error == nil,
+			ResultType:     r.ResultType,
+			CreateTime:     r.CreateTime,
+			LastUpdateTime: r.LastUpdateTime,
+			Data:           r.Details,
+		})
+	}
+	return map[string]interface{}{"asyncHandles": handles}, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
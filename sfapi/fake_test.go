@@ -0,0 +1,43 @@
+package sfapi_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+	"github.com/solidfire/solidfire-docker-driver/sfapi/fake"
+)
+
+// newFakeClient starts a fake cluster and returns it along with a client
+// that has negotiated its API version and owns a "docker" account
+func newFakeClient(t *testing.T) (*fake.Cluster, *sfapi.Client, int64) {
+	t.Helper()
+	fc := fake.NewCluster()
+	t.Cleanup(fc.Close)
+	c, err := sfapi.New(fc.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.NegotiateAPIVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	accountID, err := c.AddAccount(ctx, &sfapi.AddAccountRequest{Username: "docker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fc, c, accountID
+}
+
+func createVolume(t *testing.T, c *sfapi.Client, accountID int64, name string) sfapi.Volume {
+	t.Helper()
+	v, err := c.CreateVolume(context.Background(), &sfapi.CreateVolumeRequest{
+		Name:      name,
+		AccountID: accountID,
+		TotalSize: 1 << 30,
+	})
+	if err != nil {
+		t.Fatalf("creating volume %s: %v", name, err)
+	}
+	return v
+}
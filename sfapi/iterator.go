@@ -0,0 +1,99 @@
+package sfapi
+
+import (
+	"context"
+)
+
+const defaultPageSize = 500
+
+// VolumeIterator pages through a volume listing a page at a time rather
+// than fetching every volume in a single response:
+//
+//	it := client.ListActiveVolumesIter(ctx, 0)
+//	for it.Next() {
+//		v := it.Volume()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type VolumeIterator struct {
+	fetch    func(startID, limit int64) ([]Volume, error)
+	pageSize int64
+	startID  int64
+	page     []Volume
+	idx      int
+	done     bool
+	err      error
+}
+
+func newVolumeIterator(pageSize int64, fetch func(startID, limit int64) ([]Volume, error)) *VolumeIterator {
+	return &VolumeIterator{fetch: fetch, pageSize: pageSize, idx: -1}
+}
+
+// Next advances to the next volume, fetching another page from the cluster
+// when needed.  It returns false when there are no more volumes or an error
+// occurred.
+func (it *VolumeIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.idx++
+	if it.idx < len(it.page) {
+		return true
+	}
+	if it.done {
+		return false
+	}
+	page, err := it.fetch(it.startID, it.pageSize)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if int64(len(page)) < it.pageSize {
+		it.done = true
+	}
+	if len(page) == 0 {
+		return false
+	}
+	it.page = page
+	it.idx = 0
+	it.startID = page[len(page)-1].VolumeID + 1
+	return true
+}
+
+// Volume returns the current volume
+func (it *VolumeIterator) Volume() Volume {
+	return it.page[it.idx]
+}
+
+// Err returns the error, if any, that stopped the iteration
+func (it *VolumeIterator) Err() error {
+	return it.err
+}
+
+func (c *Client) pageSize(size int64) int64 {
+	if size > 0 {
+		return size
+	}
+	if c.Config != nil && c.Config.ListPageSize > 0 {
+		return c.Config.ListPageSize
+	}
+	return defaultPageSize
+}
+
+// ListActiveVolumesIter iterates over all active volumes on the cluster,
+// pageSize volumes at a time (0 uses the client default)
+func (c *Client) ListActiveVolumesIter(ctx context.Context, pageSize int64) *VolumeIterator {
+	return newVolumeIterator(c.pageSize(pageSize), func(startID, limit int64) ([]Volume, error) {
+		return c.ListActiveVolumes(ctx, &ListActiveVolumesRequest{StartVolumeID: startID, Limit: limit})
+	})
+}
+
+// ListVolumesForAccountIter iterates over the volumes of an account,
+// pageSize volumes at a time (0 uses the client default)
+func (c *Client) ListVolumesForAccountIter(ctx context.Context, accountID int64, pageSize int64) *VolumeIterator {
+	return newVolumeIterator(c.pageSize(pageSize), func(startID, limit int64) ([]Volume, error) {
+		return c.ListVolumesForAccount(ctx, &ListVolumesForAccountRequest{AccountID: accountID, StartVolumeID: startID, Limit: limit})
+	})
+}
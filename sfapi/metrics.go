@@ -0,0 +1,107 @@
+package sfapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus instrumentation of a Client's API calls.
+// Each Client registers its metrics with its own Registry, which the daemon
+// serves over http.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	calls   *prometheus.CounterVec
+	errors  *prometheus.CounterVec
+	retries *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+	wait    *prometheus.HistogramVec
+}
+
+// NewMetrics creates the API metrics and registers them with a new registry
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "solidfire",
+			Subsystem: "api",
+			Name:      "requests_total",
+			Help:      "Number of Element API requests, by method.",
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "solidfire",
+			Subsystem: "api",
+			Name:      "errors_total",
+			Help:      "Number of failed Element API requests, by method and Element error name (or transport/timeout).",
+		}, []string{"method", "error"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "solidfire",
+			Subsystem: "api",
+			Name:      "retries_total",
+			Help:      "Number of times an Element API request was retried, by method.",
+		}, []string{"method"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "solidfire",
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of Element API requests including retries, by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"method"}),
+		wait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "solidfire",
+			Subsystem: "api",
+			Name:      "wait_duration_seconds",
+			Help:      "Time Element API requests spent queued by the client's rate limit and in-flight cap, by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"method"}),
+	}
+	m.Registry.MustRegister(m.calls, m.errors, m.retries, m.latency, m.wait)
+	return m
+}
+
+// errorLabel returns the value of the error label for err: the Element
+// error name for API errors, otherwise the kind of failure
+func errorLabel(err error) string {
+	if name := ErrorName(err); name != "" {
+		return name
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case isConnError(err):
+		return "transport"
+	}
+	return "other"
+}
+
+// observe records a completed request, it's a no-op on a nil Metrics so
+// clients built without New don't need to set one up
+func (m *Metrics) observe(method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.calls.WithLabelValues(method).Inc()
+	m.latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(method, errorLabel(err)).Inc()
+	}
+}
+
+func (m *Metrics) retried(method string) {
+	if m == nil {
+		return
+	}
+	m.retries.WithLabelValues(method).Inc()
+}
+
+func (m *Metrics) waited(method string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.wait.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
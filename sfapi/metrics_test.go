@@ -0,0 +1,93 @@
+package sfapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// observations returns the number of samples in the named histogram for
+// method
+func observations(t *testing.T, m *Metrics, name, method string) uint64 {
+	t.Helper()
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			for _, l := range metric.GetLabel() {
+				if l.GetName() == "method" && l.GetValue() == method {
+					return metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	notFound := &APIError{Code: 500, Name: "xVolumeIDDoesNotExist", Message: "no volume"}
+	_, c := newStubClient(t, func(method string, call int) stubReply {
+		switch {
+		case method == "ListActiveVolumes" && call == 1:
+			return stubReply{err: busyError()}
+		case method == "GetVolumeStats":
+			return stubReply{err: notFound}
+		case method == "CloneVolume":
+			return stubReply{drop: true}
+		}
+		return stubReply{}
+	})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Request(ctx, "ListActiveVolumes", nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Request(ctx, "GetVolumeStats", nil, 1); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := c.Request(ctx, "CloneVolume", nil, 1); err == nil {
+		t.Fatal("expected the dropped clone to fail")
+	}
+
+	m := c.Metrics
+	// Only the requests that failed have an error series
+	if n := testutil.CollectAndCount(m.errors); n != 2 {
+		t.Errorf("expected errors for 2 method and error pairs, got %d", n)
+	}
+	for _, tc := range []struct {
+		method             string
+		calls, retries     float64
+		errorName          string
+		errors             float64
+		latencySamples     uint64
+		waitSamplesAtLeast uint64
+	}{
+		// The busy reply is retried, the request as a whole succeeds
+		{"ListActiveVolumes", 2, 1, "xUnitIsBusy", 0, 2, 3},
+		{"GetVolumeStats", 1, 0, "xVolumeIDDoesNotExist", 1, 1, 1},
+		{"CloneVolume", 1, 0, "transport", 1, 1, 1},
+	} {
+		if n := testutil.ToFloat64(m.calls.WithLabelValues(tc.method)); n != tc.calls {
+			t.Errorf("%s: expected %v requests, got %v", tc.method, tc.calls, n)
+		}
+		if n := testutil.ToFloat64(m.retries.WithLabelValues(tc.method)); n != tc.retries {
+			t.Errorf("%s: expected %v retries, got %v", tc.method, tc.retries, n)
+		}
+		if n := testutil.ToFloat64(m.errors.WithLabelValues(tc.method, tc.errorName)); n != tc.errors {
+			t.Errorf("%s: expected %v %s errors, got %v", tc.method, tc.errors, tc.errorName, n)
+		}
+		if n := observations(t, m, "solidfire_api_request_duration_seconds", tc.method); n != tc.latencySamples {
+			t.Errorf("%s: expected %d latency samples, got %d", tc.method, tc.latencySamples, n)
+		}
+		if n := observations(t, m, "solidfire_api_wait_duration_seconds", tc.method); n < tc.waitSamplesAtLeast {
+			t.Errorf("%s: expected at least %d wait samples, got %d", tc.method, tc.waitSamplesAtLeast, n)
+		}
+	}
+}
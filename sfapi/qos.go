@@ -0,0 +1,118 @@
+package sfapi
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// requireQoSPolicies returns an error if the cluster is too old to have QoS
+// policies
+func (c *Client) requireQoSPolicies() error {
+	if !c.Supports("QoSPolicies") {
+		return fmt.Errorf("QoS policies require Element API %s or later, the cluster is using %s", capabilities["QoSPolicies"], c.APIVersion())
+	}
+	return nil
+}
+
+func (c *Client) CreateQoSPolicy(ctx context.Context, req *CreateQoSPolicyRequest) (policy QoSPolicy, err error) {
+	if err := c.requireQoSPolicies(); err != nil {
+		return policy, err
+	}
+	applied := func() bool {
+		p, err := c.GetQoSPolicyByName(ctx, req.Name)
+		if err != nil {
+			return false
+		}
+		policy = p
+		return true
+	}
+	response, done, err := c.requestWithRetry(ctx, "CreateQoSPolicy", req, newReqID(), applied)
+	if err != nil {
+		log.Errorf("Failed to create QoS policy %s: %v", req.Name, err)
+		return QoSPolicy{}, err
+	}
+	if done {
+		return policy, nil
+	}
+	var result QoSPolicyResult
+	if err := decodeResponse("CreateQoSPolicy", response, &result); err != nil {
+		return QoSPolicy{}, err
+	}
+	return result.Result.QoSPolicy, nil
+}
+
+func (c *Client) ListQoSPolicies(ctx context.Context) (policies []QoSPolicy, err error) {
+	if err := c.requireQoSPolicies(); err != nil {
+		return nil, err
+	}
+	response, err := c.Request(ctx, "ListQoSPolicies", struct{}{}, newReqID())
+	if err != nil {
+		return nil, err
+	}
+	var result ListQoSPoliciesResult
+	if err := decodeResponse("ListQoSPolicies", response, &result); err != nil {
+		return nil, err
+	}
+	return result.Result.QoSPolicies, nil
+}
+
+// GetQoSPolicyByName returns the QoS policy with the given name
+func (c *Client) GetQoSPolicyByName(ctx context.Context, name string) (policy QoSPolicy, err error) {
+	policies, err := c.ListQoSPolicies(ctx)
+	if err != nil {
+		return policy, err
+	}
+	for _, p := range policies {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return policy, fmt.Errorf("Failed to find QoS policy %s: %w", name, ErrNotFound)
+}
+
+// ModifyQoSPolicy renames a QoS policy or changes its settings, the new
+// settings apply to every volume using the policy
+func (c *Client) ModifyQoSPolicy(ctx context.Context, req *ModifyQoSPolicyRequest) (policy QoSPolicy, err error) {
+	if err := c.requireQoSPolicies(); err != nil {
+		return policy, err
+	}
+	response, err := c.Request(ctx, "ModifyQoSPolicy", req, newReqID())
+	if err != nil {
+		log.Errorf("Failed to modify QoS policy %d: %v", req.QoSPolicyID, err)
+		return QoSPolicy{}, err
+	}
+	var result QoSPolicyResult
+	if err := decodeResponse("ModifyQoSPolicy", response, &result); err != nil {
+		return QoSPolicy{}, err
+	}
+	return result.Result.QoSPolicy, nil
+}
+
+func (c *Client) DeleteQoSPolicy(ctx context.Context, policyID int64) (err error) {
+	if err := c.requireQoSPolicies(); err != nil {
+		return err
+	}
+	req := DeleteQoSPolicyRequest{QoSPolicyID: policyID}
+	_, err = c.Request(ctx, "DeleteQoSPolicy", &req, newReqID())
+	if err != nil {
+		log.Errorf("Failed to delete QoS policy %d: %v", policyID, err)
+		return err
+	}
+	return nil
+}
+
+// VolTypeQoS returns the QoS settings for volumes of type t: either the ID
+// of the cluster QoS policy it names, or its inline QoS
+func (c *Client) VolTypeQoS(ctx context.Context, t VolType) (qos *QoS, policyID int64, err error) {
+	if t.QoSPolicy == "" {
+		qos := t.QOS
+		return &qos, 0, nil
+	}
+	p, err := c.GetQoSPolicyByName(ctx, t.QoSPolicy)
+	if err != nil {
+		return nil, 0, fmt.Errorf("volume type %s: %w", t.Type, err)
+	}
+	return nil, p.QoSPolicyID, nil
+}
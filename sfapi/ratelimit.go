@@ -0,0 +1,113 @@
+package sfapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter throttles the requests a Client sends to the cluster with a token
+// bucket (RateLimit requests per second, bursting to RateBurst) and caps the
+// number of requests in flight at once (MaxInFlight).  A zero value for
+// either disables that limit.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	slots chan struct{}
+}
+
+func newLimiter(rate float64, burst, maxInFlight int) *limiter {
+	if rate <= 0 && maxInFlight <= 0 {
+		return nil
+	}
+	l := &limiter{rate: rate, burst: float64(burst)}
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	l.tokens = l.burst
+	l.last = time.Now()
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// reserve takes a token from the bucket, returning how long the caller has
+// to wait before it may use it
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns a token reserved by a caller that gave up waiting
+func (l *limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
+
+// acquire waits until a request may be sent, giving up if ctx is done
+// first.  The returned func must be called once the request completes.
+func (l *limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if l.rate > 0 {
+		if delay := l.reserve(); delay > 0 {
+			t := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				l.cancel()
+				return nil, ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SetRateLimit changes the client's limits on requests sent to the cluster:
+// rate requests per second bursting to burst, with at most maxInFlight
+// outstanding.  0 disables a limit.  Requests already queued keep waiting
+// under the old limits.
+func (c *Client) SetRateLimit(rate float64, burst, maxInFlight int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limiter = newLimiter(rate, burst, maxInFlight)
+}
+
+// wait blocks until the client's limits allow another request to be sent,
+// recording the time spent queued
+func (c *Client) wait(ctx context.Context, method string) (release func(), err error) {
+	c.mu.Lock()
+	l := c.limiter
+	c.mu.Unlock()
+	start := time.Now()
+	release, err = l.acquire(ctx)
+	c.Metrics.waited(method, start)
+	return release, err
+}
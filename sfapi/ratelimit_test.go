@@ -0,0 +1,118 @@
+package sfapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterDisabled(t *testing.T) {
+	if l := newLimiter(0, 10, 0); l != nil {
+		t.Fatalf("expected no limiter without a rate or in-flight cap, got %+v", l)
+	}
+	var l *limiter
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestLimiterBurst(t *testing.T) {
+	l := newLimiter(10, 3, 0)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := l.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("expected the burst to go straight through, took %v", elapsed)
+	}
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("expected the request after the burst to wait for a token, took %v", elapsed)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := newLimiter(50, 1, 0)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := l.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request uses the burst, the other 5 wait 20ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("expected 6 requests at 50/s to take about 100ms, took %v", elapsed)
+	}
+}
+
+func TestLimiterInFlight(t *testing.T) {
+	l := newLimiter(0, 0, 2)
+	first, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := l.acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected a third request to wait while two are in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	first()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected the third request to go once one completed")
+	}
+}
+
+func TestLimiterCancelWaitingForToken(t *testing.T) {
+	l := newLimiter(1, 1, 0)
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := l.acquire(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected to stop waiting once the context expired, took %v", elapsed)
+	}
+	// The abandoned token is handed back, so the next request only waits
+	// out the rest of the first second rather than two
+	if delay := l.reserve(); delay > time.Second {
+		t.Fatalf("expected the cancelled token to be returned, next wait is %v", delay)
+	}
+}
+
+func TestLimiterCancelWaitingForSlot(t *testing.T) {
+	l := newLimiter(0, 0, 1)
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the context's error, got %v", err)
+	}
+}
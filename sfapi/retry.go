@@ -0,0 +1,147 @@
+package sfapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// RetryPolicy controls how Client.Request retries calls that fail with
+// transient errors (busy/unavailable cluster, dropped connections).
+// MaxAttempts of 1 disables retries.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialDelayMs int64
+	MaxDelayMs     int64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialDelayMs: 500,
+	MaxDelayMs:     10000,
+}
+
+// withDefaults fills in any unset fields from DefaultRetryPolicy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.InitialDelayMs <= 0 {
+		p.InitialDelayMs = DefaultRetryPolicy.InitialDelayMs
+	}
+	if p.MaxDelayMs <= 0 {
+		p.MaxDelayMs = DefaultRetryPolicy.MaxDelayMs
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry (1 based), exponential
+// with full jitter and capped at MaxDelayMs
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialDelayMs
+	for i := 1; i < retry && d < p.MaxDelayMs; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelayMs {
+		d = p.MaxDelayMs
+	}
+	return time.Duration(d/2+rand.Int63n(d/2+1)) * time.Millisecond
+}
+
+// idempotentMethods are the methods besides List* and Get* that set an
+// object to the values sent rather than changing it relative to its current
+// state, so sending one twice has the same effect as sending it once
+var idempotentMethods = map[string]bool{
+	"ModifyAccount":           true,
+	"ModifyQoSPolicy":         true,
+	"ModifySchedule":          true,
+	"ModifyVolume":            true,
+	"ModifyVolumeAccessGroup": true,
+}
+
+// isIdempotent reports whether an API method can safely be sent to the
+// cluster more than once
+func isIdempotent(method string) bool {
+	return strings.HasPrefix(method, "List") || strings.HasPrefix(method, "Get") || idempotentMethods[method]
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsBusy(err) {
+		return true
+	}
+	return isConnError(err)
+}
+
+func isConnError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// notDelivered reports whether err guarantees the cluster never acted on
+// the request, making even non-idempotent calls safe to resend
+func notDelivered(err error) bool {
+	if IsBusy(err) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// requestWithRetry issues the request, retrying transient failures according
+// to the client's RetryPolicy.  Non-idempotent methods are only resent when
+// the failure shows the request never reached the cluster, or when applied
+// is provided and reports the cluster did not carry out the previous
+// attempt.  If applied reports the previous attempt did succeed we stop and
+// return done=true with no response body.
+func (c *Client) requestWithRetry(ctx context.Context, method string, params interface{}, id int, applied func() bool) (response []byte, done bool, err error) {
+	start := time.Now()
+	defer func() { c.Metrics.observe(method, start, err) }()
+	policy := c.RetryPolicy.withDefaults()
+	for attempt := 1; ; attempt++ {
+		response, err = c.request(ctx, method, params, id)
+		if err == nil || ctx.Err() != nil || attempt >= policy.MaxAttempts || !IsRetryable(err) {
+			return response, false, err
+		}
+		if !isIdempotent(method) && !notDelivered(err) {
+			if applied == nil {
+				return response, false, err
+			}
+			if applied() {
+				log.WithFields(log.Fields{"reqID": id, "method": method}).Infof("Request %d (%s) was applied by the cluster despite error: %v", id, method, err)
+				return nil, true, nil
+			}
+		}
+		delay := policy.backoff(attempt)
+		log.WithFields(log.Fields{"reqID": id, "method": method}).Warningf("Request %d (%s) failed (attempt %d of %d), retrying in %v: %v",
+			id, method, attempt, policy.MaxAttempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(delay):
+		}
+		c.Metrics.retried(method)
+	}
+}
@@ -0,0 +1,260 @@
+package sfapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var fastRetries = RetryPolicy{MaxAttempts: 3, InitialDelayMs: 1, MaxDelayMs: 2}
+
+func busyError() *APIError {
+	return &APIError{Code: 500, Name: "xUnitIsBusy", Message: "busy"}
+}
+
+// stubReply is how the stub cluster answers a call, either with an error,
+// by dropping the connection after reading the request or with an empty
+// result
+type stubReply struct {
+	err  *APIError
+	drop bool
+}
+
+// stubCluster answers json-rpc requests with the reply picked by respond
+// for each call (1 based) of a method, counting the calls made
+type stubCluster struct {
+	mu      sync.Mutex
+	calls   map[string]int
+	respond func(method string, call int) stubReply
+}
+
+func (s *stubCluster) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *stubCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+		ID     int    `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.calls[req.Method]++
+	reply := s.respond(req.Method, s.calls[req.Method])
+	s.mu.Unlock()
+
+	if reply.drop {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+	resp := map[string]interface{}{"id": req.ID, "result": map[string]interface{}{}}
+	if reply.err != nil {
+		resp = map[string]interface{}{"id": req.ID, "error": reply.err}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// newStubClient starts a stub cluster and returns it along with a client
+// using it with fast retries
+func newStubClient(t *testing.T, respond func(method string, call int) stubReply) (*stubCluster, *Client) {
+	t.Helper()
+	s := &stubCluster{calls: map[string]int{}, respond: respond}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	c, err := New(Config{EndPoint: srv.URL, Retry: fastRetries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+// failFirst replies with reply to the first n calls of method and
+// succeeds otherwise
+func failFirst(method string, n int, reply stubReply) func(string, int) stubReply {
+	return func(m string, call int) stubReply {
+		if m == method && call <= n {
+			return reply
+		}
+		return stubReply{}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, InitialDelayMs: 100, MaxDelayMs: 1000}
+	for retry, max := range map[int]time.Duration{1: 100, 2: 200, 3: 400, 4: 800, 5: 1000, 9: 1000} {
+		max *= time.Millisecond
+		for i := 0; i < 50; i++ {
+			if d := p.backoff(retry); d < max/2 || d > max {
+				t.Fatalf("backoff for retry %d was %v, expected between %v and %v", retry, d, max/2, max)
+			}
+		}
+	}
+	if p := (RetryPolicy{}).withDefaults(); p != DefaultRetryPolicy {
+		t.Fatalf("expected an empty policy to get the defaults, got %+v", p)
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for method, want := range map[string]bool{
+		"ListActiveVolumes":       true,
+		"GetClusterVersionInfo":   true,
+		"CreateVolume":            false,
+		"DeleteVolume":            false,
+		"CloneVolume":             false,
+		"ModifyVolume":            true,
+		"ModifyQoSPolicy":         true,
+		"ModifySchedule":          true,
+		"ModifyAccount":           true,
+		"ModifyVolumeAccessGroup": true,
+	} {
+		if got := isIdempotent(method); got != want {
+			t.Errorf("isIdempotent(%s) = %t, expected %t", method, got, want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err          error
+		retryable    bool
+		notDelivered bool
+	}{
+		{nil, false, false},
+		{busyError(), true, true},
+		{fmt.Errorf("wrapped: %w", busyError()), true, true},
+		{&APIError{Code: 500, Name: "xVolumeIDDoesNotExist"}, false, false},
+		{io.EOF, true, false},
+		{syscall.ECONNRESET, true, false},
+		{syscall.ECONNREFUSED, true, true},
+		{&net.OpError{Op: "dial", Err: errors.New("no route to host")}, true, true},
+		{&net.OpError{Op: "read", Err: errors.New("timeout")}, true, false},
+		{errors.New("some other failure"), false, false},
+	} {
+		if got := IsRetryable(tc.err); got != tc.retryable {
+			t.Errorf("IsRetryable(%v) = %t, expected %t", tc.err, got, tc.retryable)
+		}
+		if tc.err == nil {
+			continue
+		}
+		if got := notDelivered(tc.err); got != tc.notDelivered {
+			t.Errorf("notDelivered(%v) = %t, expected %t", tc.err, got, tc.notDelivered)
+		}
+	}
+}
+
+func TestRetryListAndGet(t *testing.T) {
+	s, c := newStubClient(t, failFirst("ListActiveVolumes", 2, stubReply{err: busyError()}))
+	if _, err := c.Request(context.Background(), "ListActiveVolumes", nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Calls("ListActiveVolumes"); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+
+	s, c = newStubClient(t, failFirst("GetClusterInfo", 1, stubReply{drop: true}))
+	if _, err := c.Request(context.Background(), "GetClusterInfo", nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Calls("GetClusterInfo"); n != 2 {
+		t.Fatalf("expected the dropped request to be resent once, got %d attempts", n)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	s, c := newStubClient(t, failFirst("ListActiveVolumes", 100, stubReply{err: busyError()}))
+	if _, err := c.Request(context.Background(), "ListActiveVolumes", nil, 1); !IsBusy(err) {
+		t.Fatalf("expected the busy error once retries ran out, got %v", err)
+	}
+	if n := s.Calls("ListActiveVolumes"); n != fastRetries.MaxAttempts {
+		t.Fatalf("expected %d attempts, got %d", fastRetries.MaxAttempts, n)
+	}
+}
+
+func TestRetryNotForPermanentErrors(t *testing.T) {
+	notFound := &APIError{Code: 500, Name: "xVolumeIDDoesNotExist", Message: "no volume"}
+	s, c := newStubClient(t, failFirst("GetVolumeStats", 100, stubReply{err: notFound}))
+	if _, err := c.Request(context.Background(), "GetVolumeStats", nil, 1); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if n := s.Calls("GetVolumeStats"); n != 1 {
+		t.Fatalf("expected a single attempt, got %d", n)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	_, c := newStubClient(t, failFirst("ListActiveVolumes", 100, stubReply{err: busyError()}))
+	c.RetryPolicy = RetryPolicy{MaxAttempts: 10, InitialDelayMs: 10000, MaxDelayMs: 10000}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Request(ctx, "ListActiveVolumes", nil, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected to stop waiting once the context expired, took %v", elapsed)
+	}
+}
+
+func TestNonIdempotentNotResentAfterDelivery(t *testing.T) {
+	// The connection drops after the request was sent, the cluster may have
+	// acted on it and there's no way to check
+	s, c := newStubClient(t, failFirst("CloneVolume", 1, stubReply{drop: true}))
+	if _, err := c.Request(context.Background(), "CloneVolume", nil, 1); err == nil {
+		t.Fatal("expected the dropped clone to fail")
+	}
+	if n := s.Calls("CloneVolume"); n != 1 {
+		t.Fatalf("expected CloneVolume not to be resent, got %d attempts", n)
+	}
+}
+
+func TestNonIdempotentResentWhenBusy(t *testing.T) {
+	// A busy cluster rejected the request outright, so it's safe to resend
+	s, c := newStubClient(t, failFirst("CloneVolume", 1, stubReply{err: busyError()}))
+	if _, err := c.Request(context.Background(), "CloneVolume", nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Calls("CloneVolume"); n != 2 {
+		t.Fatalf("expected CloneVolume to be resent once, got %d attempts", n)
+	}
+}
+
+func TestAppliedCheck(t *testing.T) {
+	for _, applied := range []bool{false, true} {
+		s, c := newStubClient(t, failFirst("CreateVolume", 1, stubReply{drop: true}))
+		checks := 0
+		_, done, err := c.requestWithRetry(context.Background(), "CreateVolume", nil, 1, func() bool {
+			checks++
+			return applied
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Resent only when the check shows the first attempt wasn't
+		// carried out
+		calls := 2
+		if applied {
+			calls = 1
+		}
+		if checks != 1 || done != applied || s.Calls("CreateVolume") != calls {
+			t.Fatalf("applied %t: expected 1 check, done %t and %d attempts, got %d, %t and %d",
+				applied, applied, calls, checks, done, s.Calls("CreateVolume"))
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package sfapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Schedule frequencies as named by the cluster
+const (
+	FrequencyTimeInterval = "Time Interval"
+	FrequencyDaysOfWeek   = "Days Of Week"
+	FrequencyDaysOfMonth  = "Days Of Month"
+)
+
+// NewSnapshotSchedule returns a recurring schedule that snapshots the given
+// volumes, keeping each snapshot for retention (0 keeps them until deleted).
+// Set the frequency with SetHourly, SetDaily or SetWeekly before creating it.
+func NewSnapshotSchedule(name string, volumeIDs []int64, retention time.Duration) *Schedule {
+	return &Schedule{
+		Name:      name,
+		Type:      "Snapshot",
+		Recurring: true,
+		ScheduleInfo: ScheduleInfo{
+			VolumeIDs:    volumeIDs,
+			SnapshotName: name,
+			Retention:    FormatRetention(retention),
+		},
+	}
+}
+
+// FormatRetention converts d to the cluster's HH:mm:ss retention format
+func FormatRetention(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	secs := int64(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
+
+func (s *Schedule) setFrequency(freq string) {
+	if s.Attributes == nil {
+		s.Attributes = map[string]interface{}{}
+	}
+	s.Attributes["frequency"] = freq
+}
+
+// SetHourly makes the schedule run every `every` hours
+func (s *Schedule) SetHourly(every int) {
+	s.setFrequency(FrequencyTimeInterval)
+	s.Hours, s.Minutes = every, 0
+	s.Weekdays, s.Monthdays = nil, nil
+}
+
+// SetDaily makes the schedule run every day at hour:minute (cluster time)
+func (s *Schedule) SetDaily(hour, minute int) {
+	s.setFrequency(FrequencyDaysOfWeek)
+	s.Hours, s.Minutes = hour, minute
+	s.Weekdays, s.Monthdays = nil, nil
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		s.Weekdays = append(s.Weekdays, Weekday{Day: int(d), Offset: 1})
+	}
+}
+
+// SetWeekly makes the schedule run every week on day at hour:minute
+// (cluster time)
+func (s *Schedule) SetWeekly(day time.Weekday, hour, minute int) {
+	s.setFrequency(FrequencyDaysOfWeek)
+	s.Hours, s.Minutes = hour, minute
+	s.Weekdays = []Weekday{{Day: int(day), Offset: 1}}
+	s.Monthdays = nil
+}
+
+// Frequency describes how often the schedule runs, ie "every 4h", "daily at
+// 02:00" or "weekly on Sunday at 02:00"
+func (s Schedule) Frequency() string {
+	freq, _ := s.Attributes["frequency"].(string)
+	at := fmt.Sprintf("%02d:%02d", s.Hours, s.Minutes)
+	switch {
+	case freq == FrequencyTimeInterval:
+		return fmt.Sprintf("every %dh%02dm", s.Hours, s.Minutes)
+	case freq == FrequencyDaysOfWeek && len(s.Weekdays) == 7:
+		return "daily at " + at
+	case freq == FrequencyDaysOfWeek && len(s.Weekdays) == 1:
+		return fmt.Sprintf("weekly on %s at %s", time.Weekday(s.Weekdays[0].Day), at)
+	case freq == FrequencyDaysOfWeek:
+		var days []string
+		for _, d := range s.Weekdays {
+			days = append(days, time.Weekday(d.Day).String()[:3])
+		}
+		return fmt.Sprintf("%v at %s", days, at)
+	case freq == FrequencyDaysOfMonth:
+		return fmt.Sprintf("monthly on %v at %s", s.Monthdays, at)
+	}
+	return freq
+}
+
+func (c *Client) CreateSchedule(ctx context.Context, req *Schedule) (schedule Schedule, err error) {
+	applied := func() bool {
+		s, err := c.GetScheduleByName(ctx, req.Name)
+		if err != nil {
+			return false
+		}
+		schedule = s
+		return true
+	}
+	response, done, err := c.requestWithRetry(ctx, "CreateSchedule", req, newReqID(), applied)
+	if err != nil {
+		log.Errorf("Failed to create schedule %s: %v", req.Name, err)
+		return Schedule{}, err
+	}
+	if done {
+		return schedule, nil
+	}
+	var result CreateScheduleResult
+	if err := decodeResponse("CreateSchedule", response, &result); err != nil {
+		return Schedule{}, err
+	}
+	return c.GetSchedule(ctx, result.Result.ScheduleID)
+}
+
+func (c *Client) GetSchedule(ctx context.Context, scheduleID int64) (schedule Schedule, err error) {
+	req := GetScheduleRequest{ScheduleID: scheduleID}
+	response, err := c.Request(ctx, "GetSchedule", &req, newReqID())
+	if err != nil {
+		return schedule, err
+	}
+	var result ScheduleResult
+	if err := decodeResponse("GetSchedule", response, &result); err != nil {
+		return schedule, err
+	}
+	return result.Result.Schedule, nil
+}
+
+func (c *Client) ListSchedules(ctx context.Context) (schedules []Schedule, err error) {
+	response, err := c.Request(ctx, "ListSchedules", struct{}{}, newReqID())
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	var result ListSchedulesResult
+	if err := decodeResponse("ListSchedules", response, &result); err != nil {
+		return nil, err
+	}
+	return result.Result.Schedules, nil
+}
+
+// GetScheduleByName returns the schedule with the given name, ignoring any
+// that are being deleted
+func (c *Client) GetScheduleByName(ctx context.Context, name string) (schedule Schedule, err error) {
+	schedules, err := c.ListSchedules(ctx)
+	if err != nil {
+		return schedule, err
+	}
+	for _, s := range schedules {
+		if s.Name == name && !s.ToBeDeleted {
+			return s, nil
+		}
+	}
+	return schedule, fmt.Errorf("Failed to find schedule %s: %w", name, ErrNotFound)
+}
+
+// ModifySchedule replaces the settings of an existing schedule with those in
+// req, start from the schedule returned by GetSchedule to change only some
+// of them
+func (c *Client) ModifySchedule(ctx context.Context, req *Schedule) (schedule Schedule, err error) {
+	response, err := c.Request(ctx, "ModifySchedule", req, newReqID())
+	if err != nil {
+		log.Errorf("Failed to modify schedule %d: %v", req.ScheduleID, err)
+		return Schedule{}, err
+	}
+	var result ScheduleResult
+	if err := decodeResponse("ModifySchedule", response, &result); err != nil {
+		return Schedule{}, err
+	}
+	return result.Result.Schedule, nil
+}
+
+// AddVolumesToSchedule adds volumes to those snapshotted by a schedule.
+// Schedules are modified as a whole, so concurrent changes to the same
+// schedule from elsewhere can be lost.
+func (c *Client) AddVolumesToSchedule(ctx context.Context, scheduleID int64, volumeIDs []int64) (schedule Schedule, err error) {
+	s, err := c.GetSchedule(ctx, scheduleID)
+	if err != nil {
+		return schedule, err
+	}
+	for _, id := range volumeIDs {
+		found := false
+		for _, existing := range s.ScheduleInfo.VolumeIDs {
+			if existing == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.ScheduleInfo.VolumeIDs = append(s.ScheduleInfo.VolumeIDs, id)
+		}
+	}
+	return c.ModifySchedule(ctx, &s)
+}
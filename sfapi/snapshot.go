@@ -1,23 +1,26 @@
 package sfapi
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 )
 
-func (c *Client) CreateSnapshot(req *CreateSnapshotRequest) (snapshot Snapshot, err error) {
-	response, err := c.Request("CreateSnapshot", req, newReqID())
+func (c *Client) CreateSnapshot(ctx context.Context, req *CreateSnapshotRequest) (snapshot Snapshot, err error) {
+	response, err := c.Request(ctx, "CreateSnapshot", req, newReqID())
+	if err != nil {
+		return Snapshot{}, err
+	}
 	var result CreateSnapshotResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Error(err)
+	if err := decodeResponse("CreateSnapshot", response, &result); err != nil {
 		return Snapshot{}, err
 	}
-	return (c.GetSnapshot(result.Result.SnapshotID, ""))
+	return (c.GetSnapshot(ctx, result.Result.SnapshotID, ""))
 }
 
-func (c *Client) GetSnapshot(sfID int64, sfName string) (s Snapshot, err error) {
+func (c *Client) GetSnapshot(ctx context.Context, sfID int64, sfName string) (s Snapshot, err error) {
 	var listReq ListSnapshotsRequest
-	snapshots, err := c.ListSnapshots(&listReq)
+	snapshots, err := c.ListSnapshots(ctx, &listReq)
 	if err != nil {
 		return Snapshot{}, err
 	}
@@ -33,15 +36,14 @@ func (c *Client) GetSnapshot(sfID int64, sfName string) (s Snapshot, err error)
 	return s, err
 
 }
-func (c *Client) ListSnapshots(req *ListSnapshotsRequest) (snapshots []Snapshot, err error) {
-	response, err := c.Request("ListSnapshots", req, newReqID())
+func (c *Client) ListSnapshots(ctx context.Context, req *ListSnapshotsRequest) (snapshots []Snapshot, err error) {
+	response, err := c.Request(ctx, "ListSnapshots", req, newReqID())
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 	var result ListSnapshotsResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Error(err)
+	if err := decodeResponse("ListSnapshots", response, &result); err != nil {
 		return nil, err
 	}
 	snapshots = result.Result.Snapshots
@@ -49,15 +51,14 @@ func (c *Client) ListSnapshots(req *ListSnapshotsRequest) (snapshots []Snapshot,
 
 }
 
-func (c *Client) RollbackToSnapshot(req *RollbackToSnapshotRequest) (newSnapID int64, err error) {
-	response, err := c.Request("RollbackToSnapshot", req, newReqID())
+func (c *Client) RollbackToSnapshot(ctx context.Context, req *RollbackToSnapshotRequest) (newSnapID int64, err error) {
+	response, err := c.Request(ctx, "RollbackToSnapshot", req, newReqID())
 	if err != nil {
 		log.Error(err)
 		return 0, err
 	}
 	var result RollbackToSnapshotResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Error(err)
+	if err := decodeResponse("RollbackToSnapshot", response, &result); err != nil {
 		return 0, err
 	}
 	newSnapID = result.Result.SnapshotID
@@ -66,14 +67,108 @@ func (c *Client) RollbackToSnapshot(req *RollbackToSnapshotRequest) (newSnapID i
 
 }
 
-func (c *Client) DeleteSnapshot(snapshotID int64) (err error) {
+func (c *Client) DeleteSnapshot(ctx context.Context, snapshotID int64) (err error) {
 	// TODO(jdg): Add options like purge=True|False, range, ALL etc
 	var req DeleteSnapshotRequest
 	req.SnapshotID = snapshotID
-	_, err = c.Request("DeleteSnapshot", req, newReqID())
+	_, err = c.Request(ctx, "DeleteSnapshot", req, newReqID())
 	if err != nil {
 		log.Error("Failed to delete snapshot ID: ", snapshotID)
 		return err
 	}
 	return
 }
+
+// CreateGroupSnapshot takes a snapshot of several volumes at the same point
+// in time
+func (c *Client) CreateGroupSnapshot(ctx context.Context, req *CreateGroupSnapshotRequest) (group GroupSnapshot, err error) {
+	response, err := c.Request(ctx, "CreateGroupSnapshot", req, newReqID())
+	if err != nil {
+		log.Error(err)
+		return group, err
+	}
+	var result GroupSnapshotResult
+	if err := decodeResponse("CreateGroupSnapshot", response, &result); err != nil {
+		return group, err
+	}
+	return c.GetGroupSnapshotByID(ctx, result.Result.GroupSnapshotID)
+}
+
+func (c *Client) ListGroupSnapshots(ctx context.Context, req *ListGroupSnapshotsRequest) (groups []GroupSnapshot, err error) {
+	response, err := c.Request(ctx, "ListGroupSnapshots", req, newReqID())
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	var result ListGroupSnapshotsResult
+	if err := decodeResponse("ListGroupSnapshots", response, &result); err != nil {
+		return nil, err
+	}
+	return result.Result.GroupSnapshots, nil
+}
+
+func (c *Client) GetGroupSnapshotByID(ctx context.Context, groupID int64) (group GroupSnapshot, err error) {
+	groups, err := c.ListGroupSnapshots(ctx, &ListGroupSnapshotsRequest{GroupSnapshotID: groupID})
+	if err != nil {
+		return group, err
+	}
+	for _, g := range groups {
+		if g.GroupSnapshotID == groupID {
+			return g, nil
+		}
+	}
+	return group, fmt.Errorf("Failed to find group snapshot with ID %d: %w", groupID, ErrNotFound)
+}
+
+// GetGroupSnapshotByName returns the group snapshot with the given name,
+// names aren't unique so more than one match is an error
+func (c *Client) GetGroupSnapshotByName(ctx context.Context, name string) (group GroupSnapshot, err error) {
+	groups, err := c.ListGroupSnapshots(ctx, &ListGroupSnapshotsRequest{})
+	if err != nil {
+		return group, err
+	}
+	var found []GroupSnapshot
+	for _, g := range groups {
+		if g.Name == name {
+			found = append(found, g)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return group, fmt.Errorf("Failed to find group snapshot with name %s: %w", name, ErrNotFound)
+	case 1:
+		return found[0], nil
+	}
+	return group, fmt.Errorf("Found %d group snapshots with name %s", len(found), name)
+}
+
+// DeleteGroupSnapshot deletes a group snapshot, if saveMembers is set the
+// member snapshots are kept as individual snapshots
+func (c *Client) DeleteGroupSnapshot(ctx context.Context, groupID int64, saveMembers bool) (err error) {
+	req := DeleteGroupSnapshotRequest{GroupSnapshotID: groupID, SaveMembers: saveMembers}
+	_, err = c.Request(ctx, "DeleteGroupSnapshot", &req, newReqID())
+	if err != nil {
+		log.Error("Failed to delete group snapshot ID: ", groupID)
+		return err
+	}
+	return nil
+}
+
+// RollbackToGroupSnapshot rolls every volume in a group snapshot back to
+// it.  If SaveCurrentState is set the current state of the volumes is saved
+// as a new group snapshot, which is returned.
+func (c *Client) RollbackToGroupSnapshot(ctx context.Context, req *RollbackToGroupSnapshotRequest) (saved GroupSnapshot, err error) {
+	response, err := c.Request(ctx, "RollbackToGroupSnapshot", req, newReqID())
+	if err != nil {
+		log.Error(err)
+		return saved, err
+	}
+	var result GroupSnapshotResult
+	if err := decodeResponse("RollbackToGroupSnapshot", response, &result); err != nil {
+		return saved, err
+	}
+	if !req.SaveCurrentState || result.Result.GroupSnapshotID == 0 {
+		return saved, nil
+	}
+	return c.GetGroupSnapshotByID(ctx, result.Result.GroupSnapshotID)
+}
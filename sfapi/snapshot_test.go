@@ -0,0 +1,176 @@
+package sfapi_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+// groupMembers returns the snapshots of volumes that belong to group
+// snapshot groupID, by volume ID
+func groupMembers(t *testing.T, c *sfapi.Client, groupID int64) map[int64]sfapi.Snapshot {
+	t.Helper()
+	snaps, err := c.ListSnapshots(context.Background(), &sfapi.ListSnapshotsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := map[int64]sfapi.Snapshot{}
+	for _, s := range snaps {
+		if s.GroupID == groupID {
+			members[s.VolumeID] = s
+		}
+	}
+	return members
+}
+
+func TestCreateGroupSnapshot(t *testing.T) {
+	_, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	data := createVolume(t, c, accountID, "data")
+	logs := createVolume(t, c, accountID, "logs")
+
+	g, err := c.CreateGroupSnapshot(ctx, &sfapi.CreateGroupSnapshotRequest{Volumes: []int64{data.VolumeID, logs.VolumeID}, Name: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Name != "nightly" || len(g.Members) != 2 {
+		t.Fatalf("expected a group snapshot of both volumes, got %+v", g)
+	}
+	members := groupMembers(t, c, g.GroupSnapshotID)
+	for _, m := range g.Members {
+		if s, ok := members[m.VolumeID]; !ok || s.SnapshotID != m.SnapshotID {
+			t.Fatalf("expected a snapshot of volume %d in the group, got %+v", m.VolumeID, members)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		volumes []int64
+		err     string
+	}{
+		{"no volumes", nil, "xInvalidParameter"},
+		{"missing volume", []int64{data.VolumeID, 9999}, "xVolumeIDDoesNotExist"},
+	} {
+		_, err := c.CreateGroupSnapshot(ctx, &sfapi.CreateGroupSnapshotRequest{Volumes: tc.volumes, Name: tc.name})
+		if sfapi.ErrorName(err) != tc.err {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.err, err)
+		}
+	}
+	if n := len(groupMembers(t, c, 0)); n != 0 {
+		t.Fatalf("expected failed group snapshots to leave no snapshots behind, got %d", n)
+	}
+}
+
+func TestGetGroupSnapshotByName(t *testing.T) {
+	_, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	v := createVolume(t, c, accountID, "data")
+	req := &sfapi.CreateGroupSnapshotRequest{Volumes: []int64{v.VolumeID}, Name: "nightly"}
+	created, err := c.CreateGroupSnapshot(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := c.GetGroupSnapshotByName(ctx, "nightly")
+	if err != nil || g.GroupSnapshotID != created.GroupSnapshotID {
+		t.Fatalf("expected group snapshot %d, got %+v, %v", created.GroupSnapshotID, g, err)
+	}
+	if _, err := c.GetGroupSnapshotByName(ctx, "weekly"); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := c.GetGroupSnapshotByID(ctx, 9999); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// Names aren't unique, a second match is ambiguous
+	if _, err := c.CreateGroupSnapshot(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetGroupSnapshotByName(ctx, "nightly"); err == nil || !strings.Contains(err.Error(), "Found 2 group snapshots") {
+		t.Fatalf("expected the duplicate name to be reported, got %v", err)
+	}
+}
+
+func TestDeleteGroupSnapshot(t *testing.T) {
+	_, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	data := createVolume(t, c, accountID, "data")
+	logs := createVolume(t, c, accountID, "logs")
+	req := &sfapi.CreateGroupSnapshotRequest{Volumes: []int64{data.VolumeID, logs.VolumeID}}
+
+	for _, saveMembers := range []bool{false, true} {
+		g, err := c.CreateGroupSnapshot(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.DeleteGroupSnapshot(ctx, g.GroupSnapshotID, saveMembers); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetGroupSnapshotByID(ctx, g.GroupSnapshotID); !sfapi.IsNotFound(err) {
+			t.Fatalf("save members %t: expected the group snapshot to be gone, got %v", saveMembers, err)
+		}
+		// Kept members are no longer part of a group
+		snaps, err := c.ListSnapshots(ctx, &sfapi.ListSnapshotsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept := 0
+		for _, m := range g.Members {
+			for _, s := range snaps {
+				if s.SnapshotID == m.SnapshotID {
+					kept++
+				}
+			}
+		}
+		want := 0
+		if saveMembers {
+			want = len(g.Members)
+		}
+		if kept != want || len(groupMembers(t, c, g.GroupSnapshotID)) != 0 {
+			t.Fatalf("save members %t: expected %d member snapshots kept outside the group, got %d", saveMembers, want, kept)
+		}
+	}
+	if err := c.DeleteGroupSnapshot(ctx, 9999, false); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestRollbackToGroupSnapshot(t *testing.T) {
+	_, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	data := createVolume(t, c, accountID, "data")
+	logs := createVolume(t, c, accountID, "logs")
+	g, err := c.CreateGroupSnapshot(ctx, &sfapi.CreateGroupSnapshotRequest{Volumes: []int64{data.VolumeID, logs.VolumeID}, Name: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := c.RollbackToGroupSnapshot(ctx, &sfapi.RollbackToGroupSnapshotRequest{GroupSnapshotID: g.GroupSnapshotID})
+	if err != nil || saved.GroupSnapshotID != 0 {
+		t.Fatalf("expected nothing to be saved, got %+v, %v", saved, err)
+	}
+
+	saved, err = c.RollbackToGroupSnapshot(ctx, &sfapi.RollbackToGroupSnapshotRequest{GroupSnapshotID: g.GroupSnapshotID, SaveCurrentState: true, Name: "before-rollback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.GroupSnapshotID == g.GroupSnapshotID || saved.Name != "before-rollback" || len(saved.Members) != 2 {
+		t.Fatalf("expected the current state saved as a new group snapshot, got %+v", saved)
+	}
+	if members := groupMembers(t, c, saved.GroupSnapshotID); members[data.VolumeID].SnapshotID == 0 || members[logs.VolumeID].SnapshotID == 0 {
+		t.Fatalf("expected the saved group to cover both volumes, got %+v", members)
+	}
+
+	if _, err := c.RollbackToGroupSnapshot(ctx, &sfapi.RollbackToGroupSnapshotRequest{GroupSnapshotID: 9999}); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	// Every member volume must still exist
+	if err := c.DeleteVolume(ctx, logs.VolumeID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RollbackToGroupSnapshot(ctx, &sfapi.RollbackToGroupSnapshotRequest{GroupSnapshotID: g.GroupSnapshotID}); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected the deleted member volume to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package sfapi
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+var errFingerprintMismatch = errors.New("certificate fingerprint does not match the pinned CertFingerprint")
+
+// tlsConfig builds the TLS settings for the json-rpc transport from the
+// client Config.  By default the MVIP certificate is verified against the
+// system roots (plus CACertFile if set).  Setting CertFingerprint pins the
+// MVIP's leaf certificate instead of verifying its chain and hostname, so it
+// can't be combined with CACertFile, and InsecureSkipVerify must be
+// explicitly set to disable verification altogether.
+func (c *Client) tlsConfig() (*tls.Config, error) {
+	tlsConf := &tls.Config{}
+	if c.Config == nil {
+		return tlsConf, nil
+	}
+	conf := c.Config
+	if conf.CACertFile != "" && conf.CertFingerprint != "" {
+		return nil, errors.New("CACertFile and CertFingerprint can't both be set, a pinned certificate isn't verified against any CA")
+	}
+
+	if conf.CACertFile != "" {
+		pem, err := ioutil.ReadFile(conf.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CACertFile %s: %v", conf.CACertFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CACertFile %s", conf.CACertFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+
+	if conf.ClientCertFile != "" || conf.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.ClientCertFile, conf.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate %s: %v", conf.ClientCertFile, err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	if conf.CertFingerprint != "" {
+		// With multiple endpoints each MVIP may have its own certificate,
+		// so a comma separated list of fingerprints is accepted
+		pinned := map[string]bool{}
+		for _, f := range strings.Split(conf.CertFingerprint, ",") {
+			fp, err := parseFingerprint(f)
+			if err != nil {
+				return nil, err
+			}
+			pinned[fp] = true
+		}
+		// The pin replaces chain verification, which is what allows the
+		// self-signed certificates MVIPs ship with to be trusted.
+		tlsConf.InsecureSkipVerify = true
+		tlsConf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errFingerprintMismatch
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !pinned[hex.EncodeToString(sum[:])] {
+				return fmt.Errorf("%v (got sha256 %s)", errFingerprintMismatch, hex.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	} else if conf.InsecureSkipVerify {
+		tlsConf.InsecureSkipVerify = true
+	}
+	return tlsConf, nil
+}
+
+// parseFingerprint normalizes a SHA-256 fingerprint, accepting either plain
+// hex or the colon separated form printed by openssl
+func parseFingerprint(fp string) (string, error) {
+	fp = strings.ToLower(strings.Replace(strings.TrimSpace(fp), ":", "", -1))
+	b, err := hex.DecodeString(fp)
+	if err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid CertFingerprint %q, expected a SHA-256 hex digest", fp)
+	}
+	return fp, nil
+}
+
+func isTLSVerifyError(err error) bool {
+	var unknownAuth x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var verifyErr *tls.CertificateVerificationError
+	return errors.As(err, &unknownAuth) || errors.As(err, &hostErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &verifyErr) ||
+		strings.Contains(err.Error(), errFingerprintMismatch.Error())
+}
+
+// mvipHost returns the host portion of an endpoint for use in messages,
+// leaving out any credentials
+func mvipHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "unknown MVIP"
+	}
+	return u.Host
+}
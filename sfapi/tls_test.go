@@ -0,0 +1,181 @@
+package sfapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTLSStub starts a stub cluster over TLS with the httptest certificate,
+// optionally requiring a client certificate
+func newTLSStub(t *testing.T, requireClientCert bool) (*httptest.Server, *stubCluster) {
+	t.Helper()
+	s := &stubCluster{calls: map[string]int{}, respond: func(string, int) stubReply { return stubReply{} }}
+	srv := httptest.NewUnstartedServer(s)
+	if requireClientCert {
+		srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, s
+}
+
+// writePEM writes blocks of the given type to a new file in dir
+func writePEM(t *testing.T, dir, name, blockType string, blocks ...[]byte) string {
+	t.Helper()
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b})...)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeClientCert generates a self-signed client certificate and returns
+// the paths of its certificate and key files
+func writeClientCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "docker"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// tlsRequest sends a request to srv with a client built from conf
+func tlsRequest(t *testing.T, srv *httptest.Server, conf Config) error {
+	t.Helper()
+	conf.EndPoint = srv.URL
+	conf.Retry = fastRetries
+	c, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Request(context.Background(), "GetClusterInfo", nil, 1)
+	return err
+}
+
+func TestTLSVerification(t *testing.T) {
+	srv, _ := newTLSStub(t, false)
+	dir := t.TempDir()
+	ca := writePEM(t, dir, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	fp := fingerprint(srv.Certificate())
+	other := strings.Repeat("ab", sha256.Size)
+
+	for _, tc := range []struct {
+		name string
+		conf Config
+		err  string
+	}{
+		{"system roots", Config{}, "TLS verification of MVIP"},
+		{"CA bundle", Config{CACertFile: ca}, ""},
+		{"pinned", Config{CertFingerprint: fp}, ""},
+		{"pinned with openssl format", Config{CertFingerprint: strings.ToUpper(colons(fp))}, ""},
+		{"one of several pins", Config{CertFingerprint: other + "," + fp}, ""},
+		{"pin mismatch", Config{CertFingerprint: other}, "does not match the pinned CertFingerprint"},
+		{"insecure", Config{InsecureSkipVerify: true}, ""},
+	} {
+		err := tlsRequest(t, srv, tc.conf)
+		if tc.err == "" && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestTLSClientCert(t *testing.T) {
+	srv, s := newTLSStub(t, true)
+	dir := t.TempDir()
+	cert, key := writeClientCert(t, dir)
+	fp := fingerprint(srv.Certificate())
+
+	if err := tlsRequest(t, srv, Config{CertFingerprint: fp}); err == nil {
+		t.Fatal("expected the request without a client certificate to be refused")
+	}
+	if err := tlsRequest(t, srv, Config{CertFingerprint: fp, ClientCertFile: cert, ClientKeyFile: key}); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Calls("GetClusterInfo"); n != 1 {
+		t.Fatalf("expected only the request with a client certificate to arrive, got %d", n)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(empty, []byte("not a certificate\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, key := writeClientCert(t, dir)
+	fp := strings.Repeat("ab", sha256.Size)
+
+	for _, tc := range []struct {
+		name string
+		conf Config
+		err  string
+	}{
+		{"missing CA bundle", Config{CACertFile: filepath.Join(dir, "missing.pem")}, "unable to read CACertFile"},
+		{"CA bundle without certificates", Config{CACertFile: empty}, "no PEM certificates found"},
+		{"CA bundle and pin", Config{CACertFile: cert, CertFingerprint: fp}, "can't both be set"},
+		{"short pin", Config{CertFingerprint: "abcd"}, "invalid CertFingerprint"},
+		{"client cert without key", Config{ClientCertFile: cert}, "unable to load client certificate"},
+		{"client key file without a key", Config{ClientCertFile: cert, ClientKeyFile: empty}, "unable to load client certificate"},
+	} {
+		tc.conf.EndPoint = "https://127.0.0.1/json-rpc/8.0"
+		_, err := New(tc.conf)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.err, err)
+		}
+	}
+	if _, err := New(Config{EndPoint: "https://127.0.0.1/json-rpc/8.0", ClientCertFile: cert, ClientKeyFile: key}); err != nil {
+		t.Fatalf("expected a valid client certificate to load, got %v", err)
+	}
+}
+
+// colons formats a hex fingerprint the way openssl prints it
+func colons(fp string) string {
+	var parts []string
+	for i := 0; i < len(fp); i += 2 {
+		parts = append(parts, fp[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,55 @@
+package sfapi
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultRequestTimeout = 2 * time.Minute
+)
+
+// httpClient returns the client's shared http.Client, building it on first
+// use.  The underlying transport keeps connections to the MVIP alive so
+// they're reused across requests.
+func (c *Client) httpClient() (*http.Client, error) {
+	c.httpOnce.Do(func() {
+		tlsConf, err := c.tlsConfig()
+		if err != nil {
+			c.httpErr = err
+			return
+		}
+		connectTimeout := defaultConnectTimeout
+		requestTimeout := defaultRequestTimeout
+		if c.Config != nil && c.Config.ConnectTimeoutSecs > 0 {
+			connectTimeout = time.Duration(c.Config.ConnectTimeoutSecs) * time.Second
+		}
+		if c.Config != nil && c.Config.RequestTimeoutSecs > 0 {
+			requestTimeout = time.Duration(c.Config.RequestTimeoutSecs) * time.Second
+		}
+		tr := &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   connectTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:     tlsConf,
+			TLSHandshakeTimeout: connectTimeout,
+			MaxIdleConnsPerHost: 8,
+			IdleConnTimeout:     90 * time.Second,
+		}
+		var rt http.RoundTripper = tr
+		if c.Config != nil && c.Config.CassetteMode != "" {
+			cas, err := NewCassette(c.Config.CassetteFile, c.Config.CassetteMode, tr)
+			if err != nil {
+				c.httpErr = err
+				return
+			}
+			rt = cas
+		}
+		c.http = &http.Client{Transport: rt, Timeout: requestTimeout}
+	})
+	return c.http, c.httpErr
+}
@@ -1,12 +1,12 @@
 package sfapi
 
-type APIError struct {
-	Id    int `json:"id"`
-	Error struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-		Name    string `json:"name"`
-	} `json:"error"`
+import (
+	"encoding/json"
+)
+
+type APIErrorResponse struct {
+	Id    int       `json:"id"`
+	Error *APIError `json:"error"`
 }
 
 type QoS struct {
@@ -36,6 +36,7 @@ type Volume struct {
 	ScsiEUIDeviceID    string       `json:"scsiEUIDeviceID"`
 	ScsiNAADeviceID    string       `json:"scsiNAADeviceID"`
 	Qos                QoS          `json:"qos"`
+	QoSPolicyID        int64        `json:"qosPolicyID"`
 	VolumeAccessGroups []int64      `json:"volumeAccessGroups"`
 	VolumePairs        []VolumePair `json:"volumePairs"`
 	DeleteTime         string       `json:"deleteTime"`
@@ -60,7 +61,9 @@ type Snapshot struct {
 }
 
 type ListVolumesForAccountRequest struct {
-	AccountID int64 `json:"accountID"`
+	AccountID     int64 `json:"accountID"`
+	StartVolumeID int64 `json:"startVolumeID,omitempty"`
+	Limit         int64 `json:"limit,omitempty"`
 }
 
 type ListActiveVolumesRequest struct {
@@ -76,12 +79,13 @@ type ListVolumesResult struct {
 }
 
 type CreateVolumeRequest struct {
-	Name       string      `json:"name"`
-	AccountID  int64       `json:"accountID"`
-	TotalSize  int64       `json:"totalSize"`
-	Enable512e bool        `json:"enable512e"`
-	Qos        QoS         `json:"qos,omitempty"`
-	Attributes interface{} `json:"attributes"`
+	Name        string      `json:"name"`
+	AccountID   int64       `json:"accountID"`
+	TotalSize   int64       `json:"totalSize"`
+	Enable512e  bool        `json:"enable512e"`
+	Qos         *QoS        `json:"qos,omitempty"`
+	QoSPolicyID int64       `json:"qosPolicyID,omitempty"`
+	Attributes  interface{} `json:"attributes"`
 }
 
 type CreateVolumeResult struct {
@@ -131,6 +135,23 @@ type DeleteVolumeRequest struct {
 	VolumeID int64 `json:"volumeID"`
 }
 
+type ListDeletedVolumesRequest struct {
+	IncludeVirtualVolumes bool `json:"includeVirtualVolumes"`
+}
+
+type RestoreDeletedVolumeRequest struct {
+	VolumeID int64 `json:"volumeID"`
+}
+
+type PurgeDeletedVolumeRequest struct {
+	VolumeID int64 `json:"volumeID"`
+}
+
+type PurgeDeletedVolumesRequest struct {
+	VolumeIDs  []int64 `json:"volumeIDs,omitempty"`
+	AccountIDs []int64 `json:"accountIDs,omitempty"`
+}
+
 type ISCSITarget struct {
 	Ip        string
 	Port      string
@@ -195,6 +216,33 @@ type AddInitiatorsToVolumeAccessGroupRequest struct {
 	VAGID      int64    `json:"volumeAccessGroupID"`
 }
 
+type RemoveInitiatorsFromVolumeAccessGroupRequest struct {
+	Initiators []string `json:"initiators"`
+	VAGID      int64    `json:"volumeAccessGroupID"`
+}
+
+type RemoveVolumesFromVolumeAccessGroupRequest struct {
+	VolumeAccessGroupID int64   `json:"volumeAccessGroupID"`
+	Volumes             []int64 `json:"volumes"`
+}
+
+type ModifyVolumeAccessGroupRequest struct {
+	VAGID      int64       `json:"volumeAccessGroupID"`
+	Name       string      `json:"name,omitempty"`
+	Attributes interface{} `json:"attributes,omitempty"`
+}
+
+type DeleteVolumeAccessGroupRequest struct {
+	VAGID int64 `json:"volumeAccessGroupID"`
+}
+
+type VolumeAccessGroupResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		VolumeAccessGroup VolumeAccessGroup `json:"volumeAccessGroup"`
+	} `json:"result"`
+}
+
 type ListVolumeAccessGroupsRequest struct {
 	StartVAGID int64 `json:"startVolumeAccessGroupID,omitempty"`
 	Limit      int64 `json:"limit,omitempty"`
@@ -242,15 +290,15 @@ type Account struct {
 	Username        string      `json:"username,omitempty"`
 	Status          string      `json:"status,omitempty"`
 	Volumes         []int64     `json:"volumes,omitempty"`
-	InitiatorSecret string      `json:"initiatorSecret,omitempty"`
-	TargetSecret    string      `json:"targetSecret,omitempty"`
+	InitiatorSecret Secret      `json:"initiatorSecret,omitempty"`
+	TargetSecret    Secret      `json:"targetSecret,omitempty"`
 	Attributes      interface{} `json:"attributes,omitempty"`
 }
 
 type AddAccountRequest struct {
 	Username        string      `json:"username"`
-	InitiatorSecret string      `json:"initiatorSecret,omitempty"`
-	TargetSecret    string      `json:"targetSecret,omitempty"`
+	InitiatorSecret Secret      `json:"initiatorSecret,omitempty"`
+	TargetSecret    Secret      `json:"targetSecret,omitempty"`
 	Attributes      interface{} `json:"attributes,omitempty"`
 }
 
@@ -260,3 +308,429 @@ type AddAccountResult struct {
 		AccountID int64 `json:"accountID"`
 	} `json:"result"`
 }
+
+type ListAccountsRequest struct {
+	StartAccountID int64 `json:"startAccountID,omitempty"`
+	Limit          int64 `json:"limit,omitempty"`
+}
+
+type ListAccountsResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		Accounts []Account `json:"accounts"`
+	} `json:"result"`
+}
+
+type ModifyAccountRequest struct {
+	AccountID       int64       `json:"accountID"`
+	Username        string      `json:"username,omitempty"`
+	Status          string      `json:"status,omitempty"`
+	InitiatorSecret Secret      `json:"initiatorSecret,omitempty"`
+	TargetSecret    Secret      `json:"targetSecret,omitempty"`
+	Attributes      interface{} `json:"attributes,omitempty"`
+}
+
+type RemoveAccountRequest struct {
+	AccountID int64 `json:"accountID"`
+}
+
+type GetAccountEfficiencyRequest struct {
+	AccountID int64 `json:"accountID"`
+}
+
+type AccountEfficiency struct {
+	Compression      float64 `json:"compression"`
+	Deduplication    float64 `json:"deduplication"`
+	ThinProvisioning float64 `json:"thinProvisioning"`
+	MissingVolumes   []int64 `json:"missingVolumes"`
+	Timestamp        string  `json:"timestamp"`
+}
+
+type GetAccountEfficiencyResult struct {
+	Id     int               `json:"id"`
+	Result AccountEfficiency `json:"result"`
+}
+
+type GetClusterVersionInfoResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		ClusterAPIVersion  string `json:"clusterAPIVersion"`
+		ClusterVersion     string `json:"clusterVersion"`
+		ClusterVersionInfo []struct {
+			NodeID               int64  `json:"nodeID"`
+			NodeInternalRevision string `json:"nodeInternalRevision"`
+			NodeVersion          string `json:"nodeVersion"`
+		} `json:"clusterVersionInfo"`
+	} `json:"result"`
+}
+
+type ClusterInfo struct {
+	Name       string      `json:"name"`
+	MVIP       string      `json:"mvip"`
+	SVIP       string      `json:"svip"`
+	UniqueID   string      `json:"uniqueID"`
+	UUID       string      `json:"uuid"`
+	RepCount   int64       `json:"repCount"`
+	Ensemble   []string    `json:"ensemble"`
+	Attributes interface{} `json:"attributes"`
+}
+
+type GetClusterInfoResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		ClusterInfo ClusterInfo `json:"clusterInfo"`
+	} `json:"result"`
+}
+
+type ClusterCapacity struct {
+	ActiveBlockSpace          int64  `json:"activeBlockSpace"`
+	ActiveSessions            int64  `json:"activeSessions"`
+	AverageIOPS               int64  `json:"averageIOPS"`
+	CurrentIOPS               int64  `json:"currentIOPS"`
+	MaxIOPS                   int64  `json:"maxIOPS"`
+	PeakIOPS                  int64  `json:"peakIOPS"`
+	MaxOverProvisionableSpace int64  `json:"maxOverProvisionableSpace"`
+	MaxProvisionedSpace       int64  `json:"maxProvisionedSpace"`
+	MaxUsedMetadataSpace      int64  `json:"maxUsedMetadataSpace"`
+	MaxUsedSpace              int64  `json:"maxUsedSpace"`
+	NonZeroBlocks             int64  `json:"nonZeroBlocks"`
+	ProvisionedSpace          int64  `json:"provisionedSpace"`
+	SnapshotNonZeroBlocks     int64  `json:"snapshotNonZeroBlocks"`
+	UniqueBlocks              int64  `json:"uniqueBlocks"`
+	UniqueBlocksUsedSpace     int64  `json:"uniqueBlocksUsedSpace"`
+	UsedMetadataSpace         int64  `json:"usedMetadataSpace"`
+	UsedSpace                 int64  `json:"usedSpace"`
+	ZeroBlocks                int64  `json:"zeroBlocks"`
+	Timestamp                 string `json:"timestamp"`
+}
+
+type GetClusterCapacityResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		ClusterCapacity ClusterCapacity `json:"clusterCapacity"`
+	} `json:"result"`
+}
+
+type ClusterFullThreshold struct {
+	BlockFullness                string `json:"blockFullness"`
+	MetadataFullness             string `json:"metadataFullness"`
+	Fullness                     string `json:"fullness"`
+	Stage2AwareThreshold         int64  `json:"stage2AwareThreshold"`
+	Stage3BlockThresholdBytes    int64  `json:"stage3BlockThresholdBytes"`
+	Stage4BlockThresholdBytes    int64  `json:"stage4BlockThresholdBytes"`
+	Stage5BlockThresholdBytes    int64  `json:"stage5BlockThresholdBytes"`
+	SumTotalClusterBytes         int64  `json:"sumTotalClusterBytes"`
+	SumUsedClusterBytes          int64  `json:"sumUsedClusterBytes"`
+	SumTotalMetadataClusterBytes int64  `json:"sumTotalMetadataClusterBytes"`
+	SumUsedMetadataClusterBytes  int64  `json:"sumUsedMetadataClusterBytes"`
+}
+
+type GetClusterFullThresholdResult struct {
+	Id     int                  `json:"id"`
+	Result ClusterFullThreshold `json:"result"`
+}
+
+type ListClusterFaultsRequest struct {
+	BestPractices bool   `json:"bestPractices"`
+	FaultTypes    string `json:"faultTypes,omitempty"` //current, resolved or all
+}
+
+type ClusterFault struct {
+	ClusterFaultID int64       `json:"clusterFaultID"`
+	Code           string      `json:"code"`
+	Type           string      `json:"type"`
+	Severity       string      `json:"severity"`
+	Details        string      `json:"details"`
+	Data           interface{} `json:"data"`
+	Date           string      `json:"date"`
+	NodeID         int64       `json:"nodeID"`
+	DriveID        int64       `json:"driveID"`
+	ServiceID      int64       `json:"serviceID"`
+	Resolved       bool        `json:"resolved"`
+	ResolvedDate   string      `json:"resolvedDate"`
+}
+
+type ListClusterFaultsResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		Faults []ClusterFault `json:"faults"`
+	} `json:"result"`
+}
+
+type ListEventsRequest struct {
+	MaxEvents    int64  `json:"maxEvents,omitempty"`
+	StartEventID int64  `json:"startEventID,omitempty"`
+	EndEventID   int64  `json:"endEventID,omitempty"`
+	EventType    string `json:"eventType,omitempty"`
+}
+
+type Event struct {
+	EventID       int64       `json:"eventID"`
+	EventInfoType string      `json:"eventInfoType"`
+	Message       string      `json:"message"`
+	Details       interface{} `json:"details"`
+	Severity      int         `json:"severity"`
+	NodeID        int64       `json:"nodeID"`
+	DriveID       int64       `json:"driveID"`
+	ServiceID     int64       `json:"serviceID"`
+	TimeOfReport  string      `json:"timeOfReport"`
+	TimeOfPublish string      `json:"timeOfPublish"`
+}
+
+type ListEventsResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		Events []Event `json:"events"`
+	} `json:"result"`
+}
+
+type GetAsyncResultRequest struct {
+	AsyncHandle int64 `json:"asyncHandle"`
+	KeepResult  bool  `json:"keepResult,omitempty"`
+}
+
+type AsyncResult struct {
+	Status         string                 `json:"status"`
+	ResultType     string                 `json:"resultType"`
+	CreateTime     string                 `json:"createTime"`
+	LastUpdateTime string                 `json:"lastUpdateTime"`
+	Details        map[string]interface{} `json:"details"`
+	Result         json.RawMessage        `json:"result"`
+	Error          *APIError              `json:"error"`
+}
+
+type GetAsyncResultResult struct {
+	Id     int         `json:"id"`
+	Result AsyncResult `json:"result"`
+}
+
+type ListAsyncResultsRequest struct {
+	AsyncResultTypes []string `json:"asyncResultTypes,omitempty"`
+}
+
+type AsyncHandle struct {
+	AsyncResultID  int64                  `json:"asyncResultID"`
+	Completed      bool                   `json:"completed"`
+	Success        bool                   `json:"success"`
+	ResultType     string                 `json:"resultType"`
+	CreateTime     string                 `json:"createTime"`
+	LastUpdateTime string                 `json:"lastUpdateTime"`
+	Data           map[string]interface{} `json:"data"`
+}
+
+type ListAsyncResultsResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		AsyncHandles []AsyncHandle `json:"asyncHandles"`
+	} `json:"result"`
+}
+
+type CopyVolumeRequest struct {
+	VolumeID    int64 `json:"volumeID"`
+	DstVolumeID int64 `json:"dstVolumeID"`
+	SnapshotID  int64 `json:"snapshotID,omitempty"`
+}
+
+type CopyVolumeResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		CloneID     int64 `json:"cloneID"`
+		AsyncHandle int64 `json:"asyncHandle"`
+	} `json:"result"`
+}
+
+type CloneMultipleVolumeParam struct {
+	VolumeID     int64       `json:"volumeID"`
+	Name         string      `json:"name,omitempty"`
+	NewAccountID int64       `json:"newAccountID,omitempty"`
+	NewSize      int64       `json:"newSize,omitempty"`
+	Access       string      `json:"access,omitempty"`
+	Attributes   interface{} `json:"attributes,omitempty"`
+}
+
+type CloneMultipleVolumesRequest struct {
+	Volumes         []CloneMultipleVolumeParam `json:"volumes"`
+	Access          string                     `json:"access,omitempty"`
+	GroupSnapshotID int64                      `json:"groupSnapshotID,omitempty"`
+	NewAccountID    int64                      `json:"newAccountID,omitempty"`
+}
+
+type CloneMultipleVolumesResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		AsyncHandle  int64 `json:"asyncHandle"`
+		GroupCloneID int64 `json:"groupCloneID"`
+		Members      []struct {
+			VolumeID    int64 `json:"volumeID"`
+			SrcVolumeID int64 `json:"srcVolumeID"`
+		} `json:"members"`
+	} `json:"result"`
+}
+
+type ModifyVolumeRequest struct {
+	VolumeID               int64       `json:"volumeID"`
+	AccountID              int64       `json:"accountID,omitempty"`
+	Access                 string      `json:"access,omitempty"`
+	Qos                    *QoS        `json:"qos,omitempty"`
+	QoSPolicyID            int64       `json:"qosPolicyID,omitempty"`
+	AssociateWithQoSPolicy *bool       `json:"associateWithQoSPolicy,omitempty"` //false detaches the volume from its QoS policy
+	TotalSize              int64       `json:"totalSize,omitempty"`
+	Attributes             interface{} `json:"attributes,omitempty"`
+}
+
+type ModifyVolumeResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		Volume Volume `json:"volume"`
+	} `json:"result"`
+}
+
+type QoSPolicy struct {
+	QoSPolicyID int64   `json:"qosPolicyID"`
+	Name        string  `json:"name"`
+	Qos         QoS     `json:"qos"`
+	VolumeIDs   []int64 `json:"volumeIDs"`
+}
+
+type CreateQoSPolicyRequest struct {
+	Name string `json:"name"`
+	Qos  QoS    `json:"qos"`
+}
+
+type QoSPolicyResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		QoSPolicy QoSPolicy `json:"qosPolicy"`
+	} `json:"result"`
+}
+
+type ListQoSPoliciesResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		QoSPolicies []QoSPolicy `json:"qosPolicies"`
+	} `json:"result"`
+}
+
+type ModifyQoSPolicyRequest struct {
+	QoSPolicyID int64  `json:"qosPolicyID"`
+	Name        string `json:"name,omitempty"`
+	Qos         *QoS   `json:"qos,omitempty"`
+}
+
+type DeleteQoSPolicyRequest struct {
+	QoSPolicyID int64 `json:"qosPolicyID"`
+}
+
+type GroupSnapshotMember struct {
+	VolumeID     int64  `json:"volumeID"`
+	SnapshotID   int64  `json:"snapshotID"`
+	SnapshotUUID string `json:"snapshotUUID"`
+	Checksum     string `json:"checksum"`
+}
+
+type GroupSnapshot struct {
+	GroupSnapshotID   int64                 `json:"groupSnapshotID"`
+	GroupSnapshotUUID string                `json:"groupSnapshotUUID"`
+	Name              string                `json:"name"`
+	Status            string                `json:"status"`
+	CreateTime        string                `json:"createTime"`
+	Members           []GroupSnapshotMember `json:"members"`
+	Attributes        interface{}           `json:"attributes"`
+}
+
+type CreateGroupSnapshotRequest struct {
+	Volumes                 []int64     `json:"volumes"`
+	Name                    string      `json:"name,omitempty"`
+	EnableRemoteReplication bool        `json:"enableRemoteReplication,omitempty"`
+	Retention               string      `json:"retention,omitempty"`
+	Attributes              interface{} `json:"attributes,omitempty"`
+}
+
+type GroupSnapshotResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		GroupSnapshotID   int64                 `json:"groupSnapshotID"`
+		GroupSnapshotUUID string                `json:"groupSnapshotUUID"`
+		Members           []GroupSnapshotMember `json:"members"`
+	} `json:"result"`
+}
+
+type ListGroupSnapshotsRequest struct {
+	Volumes         []int64 `json:"volumes,omitempty"`
+	GroupSnapshotID int64   `json:"groupSnapshotID,omitempty"`
+}
+
+type ListGroupSnapshotsResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		GroupSnapshots []GroupSnapshot `json:"groupSnapshots"`
+	} `json:"result"`
+}
+
+type DeleteGroupSnapshotRequest struct {
+	GroupSnapshotID int64 `json:"groupSnapshotID"`
+	SaveMembers     bool  `json:"saveMembers"`
+}
+
+type RollbackToGroupSnapshotRequest struct {
+	GroupSnapshotID  int64       `json:"groupSnapshotID"`
+	SaveCurrentState bool        `json:"saveCurrentState"`
+	Name             string      `json:"name,omitempty"`
+	Attributes       interface{} `json:"attributes,omitempty"`
+}
+
+type Weekday struct {
+	Day    int `json:"day"`    //0 is Sunday
+	Offset int `json:"offset"` //1 for every week
+}
+
+type ScheduleInfo struct {
+	VolumeIDs               []int64 `json:"volumes,omitempty"`
+	SnapshotName            string  `json:"name,omitempty"`
+	Retention               string  `json:"retention,omitempty"` //HH:mm:ss
+	EnableRemoteReplication bool    `json:"enableRemoteReplication,omitempty"`
+}
+
+type Schedule struct {
+	ScheduleID         int64                  `json:"scheduleID,omitempty"`
+	Name               string                 `json:"scheduleName"`
+	Type               string                 `json:"scheduleType"`
+	Attributes         map[string]interface{} `json:"attributes"`
+	Hours              int                    `json:"hours"`
+	Minutes            int                    `json:"minutes"`
+	Weekdays           []Weekday              `json:"weekdays,omitempty"`
+	Monthdays          []int                  `json:"monthdays,omitempty"`
+	ScheduleInfo       ScheduleInfo           `json:"scheduleInfo"`
+	Paused             bool                   `json:"paused"`
+	Recurring          bool                   `json:"recurring"`
+	StartingDate       string                 `json:"startingDate,omitempty"`
+	LastRunStatus      string                 `json:"lastRunStatus,omitempty"`
+	LastRunTimeStarted string                 `json:"lastRunTimeStarted,omitempty"`
+	HasError           bool                   `json:"hasError,omitempty"`
+	ToBeDeleted        bool                   `json:"toBeDeleted,omitempty"`
+}
+
+type CreateScheduleResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		ScheduleID int64 `json:"scheduleID"`
+	} `json:"result"`
+}
+
+type GetScheduleRequest struct {
+	ScheduleID int64 `json:"scheduleID"`
+}
+
+type ScheduleResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		Schedule Schedule `json:"schedule"`
+	} `json:"result"`
+}
+
+type ListSchedulesResult struct {
+	Id     int `json:"id"`
+	Result struct {
+		Schedules []Schedule `json:"schedules"`
+	} `json:"result"`
+}
@@ -1,6 +1,7 @@
 package sfapi
 
 import (
+	"context"
 	log "github.com/Sirupsen/logrus"
 	"os"
 	"os/exec"
@@ -85,14 +86,14 @@ func iscsiLogin(tgt *ISCSITarget) (err error) {
 	return err
 }
 
-func iscsiDisableDelete(tgt *ISCSITarget) (err error) {
+func iscsiDisableDelete(ctx context.Context, tgt *ISCSITarget) (err error) {
 	log.Debugf("Begin utils.iscsiDisableDelete: %v", tgt)
-	_, err = exec.Command("sudo", "iscsiadm", "-m", "node", "-T", tgt.Iqn, "--portal", tgt.Ip, "-u").CombinedOutput()
+	_, err = exec.CommandContext(ctx, "sudo", "iscsiadm", "-m", "node", "-T", tgt.Iqn, "--portal", tgt.Ip, "-u").CombinedOutput()
 	if err != nil {
 		log.Debugf("Error during iscsi logout: ", err)
 		//return
 	}
-	_, err = exec.Command("sudo", "iscsiadm", "-m", "node", "-o", "delete", "-T", tgt.Iqn).CombinedOutput()
+	_, err = exec.CommandContext(ctx, "sudo", "iscsiadm", "-m", "node", "-o", "delete", "-T", tgt.Iqn).CombinedOutput()
 	return
 }
 
@@ -177,8 +178,10 @@ func LoginWithChap(tiqn, portal, username, password, iface string) error {
 		return err
 	}
 	authPasswordArgs := append(args, []string{"--op=update", "--name", "node.session.auth.password", "--value=" + password}...)
-	if _, err := exec.Command("iscsiadm", authPasswordArgs...).CombinedOutput(); err != nil {
-		log.Error(os.Stderr, "Error running iscsiadm set authpassword: ", err)
+	if out, err := exec.Command("iscsiadm", authPasswordArgs...).CombinedOutput(); err != nil {
+		// NOTE: the CHAP secret is on the iscsiadm command line, make sure it
+		// never ends up in the logs via its output
+		log.Error("Error running iscsiadm set authpassword: ", err, "{", redactString(string(out), password), "}")
 		return err
 	}
 	loginArgs := append(args, []string{"--login"}...)
@@ -1,15 +1,19 @@
 package sfapi
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
+	"strings"
 )
 
-func (c *Client) CreateVolumeAccessGroup(r *CreateVolumeAccessGroupRequest) (vagID int64, err error) {
+func (c *Client) CreateVolumeAccessGroup(ctx context.Context, r *CreateVolumeAccessGroupRequest) (vagID int64, err error) {
 	var result CreateVolumeAccessGroupResult
-	response, err := c.Request("CreateVolumeAccessGroup", r, newReqID())
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	response, err := c.Request(ctx, "CreateVolumeAccessGroup", r, newReqID())
+	if err != nil {
+		return 0, err
+	}
+	if err := decodeResponse("CreateVolumeAccessGroup", response, &result); err != nil {
 		return 0, err
 	}
 	vagID = result.Result.VagID
@@ -17,23 +21,22 @@ func (c *Client) CreateVolumeAccessGroup(r *CreateVolumeAccessGroupRequest) (vag
 
 }
 
-func (c *Client) ListVolumeAccessGroups(r *ListVolumeAccessGroupsRequest) (vags []VolumeAccessGroup, err error) {
-	response, err := c.Request("ListVolumeAccessGroups", r, newReqID())
+func (c *Client) ListVolumeAccessGroups(ctx context.Context, r *ListVolumeAccessGroupsRequest) (vags []VolumeAccessGroup, err error) {
+	response, err := c.Request(ctx, "ListVolumeAccessGroups", r, newReqID())
 	if err != nil {
 		log.Error(err)
 		return
 	}
 	var result ListVolumesAccessGroupsResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	if err := decodeResponse("ListVolumeAccessGroups", response, &result); err != nil {
 		return nil, err
 	}
 	vags = result.Result.Vags
 	return
 }
 
-func (c *Client) AddInitiatorsToVolumeAccessGroup(r *AddInitiatorsToVolumeAccessGroupRequest) error {
-	response, err := c.Request("AddInitiatorsToVolumeAccessGroup", r, newReqID())
+func (c *Client) AddInitiatorsToVolumeAccessGroup(ctx context.Context, r *AddInitiatorsToVolumeAccessGroupRequest) error {
+	response, err := c.Request(ctx, "AddInitiatorsToVolumeAccessGroup", r, newReqID())
 	if err != nil {
 		log.Error(string(response))
 		log.Error(err)
@@ -41,3 +44,105 @@ func (c *Client) AddInitiatorsToVolumeAccessGroup(r *AddInitiatorsToVolumeAccess
 	}
 	return nil
 }
+
+func (c *Client) GetVolumeAccessGroupByID(ctx context.Context, vagID int64) (vag VolumeAccessGroup, err error) {
+	req := ListVolumeAccessGroupsRequest{StartVAGID: vagID, Limit: 1}
+	vags, err := c.ListVolumeAccessGroups(ctx, &req)
+	if err != nil {
+		return vag, err
+	}
+	// Like GetVolumeByID, the listing starts at vagID so a missing group
+	// returns the next one
+	if len(vags) < 1 || vags[0].VAGID != vagID {
+		return vag, fmt.Errorf("Failed to find VAG with ID %d: %w", vagID, ErrNotFound)
+	}
+	return vags[0], nil
+}
+
+func (c *Client) GetVolumeAccessGroupByName(ctx context.Context, name string) (vag VolumeAccessGroup, err error) {
+	vags, err := c.ListVolumeAccessGroups(ctx, &ListVolumeAccessGroupsRequest{})
+	if err != nil {
+		return vag, err
+	}
+	for _, g := range vags {
+		if g.Name == name {
+			return g, nil
+		}
+	}
+	return vag, fmt.Errorf("Failed to find VAG with name %s: %w", name, ErrNotFound)
+}
+
+// ModifyVolumeAccessGroup renames a VAG or replaces its attributes
+func (c *Client) ModifyVolumeAccessGroup(ctx context.Context, r *ModifyVolumeAccessGroupRequest) (vag VolumeAccessGroup, err error) {
+	_, err = c.Request(ctx, "ModifyVolumeAccessGroup", r, newReqID())
+	if err != nil {
+		log.Errorf("Failed to modify VAG %d: %v", r.VAGID, err)
+		return vag, err
+	}
+	return c.GetVolumeAccessGroupByID(ctx, r.VAGID)
+}
+
+// DeleteVolumeAccessGroup deletes a VAG, its volumes and initiators are
+// left in place but lose access through it
+func (c *Client) DeleteVolumeAccessGroup(ctx context.Context, vagID int64) error {
+	applied := func() bool {
+		_, err := c.GetVolumeAccessGroupByID(ctx, vagID)
+		return IsNotFound(err)
+	}
+	req := DeleteVolumeAccessGroupRequest{VAGID: vagID}
+	_, _, err := c.requestWithRetry(ctx, "DeleteVolumeAccessGroup", &req, newReqID(), applied)
+	if err != nil {
+		log.Error("Failed to delete VAG ID: ", vagID)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) RemoveVolumesFromVolumeAccessGroup(ctx context.Context, vagID int64, volIDs []int64) error {
+	// Removing a volume that's no longer in the group fails, so only
+	// resend if some of them are still there
+	applied := func() bool {
+		g, err := c.GetVolumeAccessGroupByID(ctx, vagID)
+		if err != nil {
+			return false
+		}
+		for _, id := range volIDs {
+			for _, v := range g.Volumes {
+				if v == id {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	req := RemoveVolumesFromVolumeAccessGroupRequest{VolumeAccessGroupID: vagID, Volumes: volIDs}
+	_, _, err := c.requestWithRetry(ctx, "RemoveVolumesFromVolumeAccessGroup", &req, newReqID(), applied)
+	if err != nil {
+		log.Errorf("Failed to remove volume(s) %v from VAG %d: %v", volIDs, vagID, err)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) RemoveInitiatorsFromVolumeAccessGroup(ctx context.Context, r *RemoveInitiatorsFromVolumeAccessGroupRequest) error {
+	applied := func() bool {
+		g, err := c.GetVolumeAccessGroupByID(ctx, r.VAGID)
+		if err != nil {
+			return false
+		}
+		for _, i := range r.Initiators {
+			for _, existing := range g.Initiators {
+				if strings.EqualFold(existing, i) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	_, _, err := c.requestWithRetry(ctx, "RemoveInitiatorsFromVolumeAccessGroup", r, newReqID(), applied)
+	if err != nil {
+		log.Errorf("Failed to remove initiator(s) %v from VAG %d: %v", r.Initiators, r.VAGID, err)
+		return err
+	}
+	return nil
+}
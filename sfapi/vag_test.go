@@ -0,0 +1,165 @@
+package sfapi_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+	"github.com/solidfire/solidfire-docker-driver/sfapi/fake"
+)
+
+const (
+	initiator1 = "iqn.1993-08.org.debian:01:host1"
+	initiator2 = "iqn.1993-08.org.debian:01:host2"
+)
+
+// createVAG creates a VAG holding two volumes and two initiators
+func createVAG(t *testing.T, c *sfapi.Client, accountID int64) (sfapi.VolumeAccessGroup, sfapi.Volume, sfapi.Volume) {
+	t.Helper()
+	data := createVolume(t, c, accountID, "data")
+	logs := createVolume(t, c, accountID, "logs")
+	ctx := context.Background()
+	id, err := c.CreateVolumeAccessGroup(ctx, &sfapi.CreateVolumeAccessGroupRequest{
+		Name:       "docker",
+		Volumes:    []int64{data.VolumeID, logs.VolumeID},
+		Initiators: []string{initiator1, initiator2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := c.GetVolumeAccessGroupByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, data, logs
+}
+
+func TestModifyVolumeAccessGroup(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	c.RetryPolicy = fastRetries
+	ctx := context.Background()
+	g, _, _ := createVAG(t, c, accountID)
+	if _, err := c.CreateVolumeAccessGroup(ctx, &sfapi.CreateVolumeAccessGroupRequest{Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ModifyVolumeAccessGroup is idempotent, so a lost response is resent
+	fc.InjectFault(fake.Fault{Method: "ModifyVolumeAccessGroup", Drop: true, Apply: true, Count: 1})
+	modified, err := c.ModifyVolumeAccessGroup(ctx, &sfapi.ModifyVolumeAccessGroupRequest{VAGID: g.VAGID, Name: "swarm", Attributes: map[string]interface{}{"owner": "ops"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modified.Name != "swarm" || fc.Calls("ModifyVolumeAccessGroup") != 2 || len(modified.Volumes) != 2 {
+		t.Fatalf("expected the VAG renamed with its members kept, got %+v after %d attempts", modified, fc.Calls("ModifyVolumeAccessGroup"))
+	}
+	if _, err := c.GetVolumeAccessGroupByName(ctx, "swarm"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetVolumeAccessGroupByName(ctx, "docker"); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected the old name to be gone, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		req  sfapi.ModifyVolumeAccessGroupRequest
+		err  string
+	}{
+		{"duplicate name", sfapi.ModifyVolumeAccessGroupRequest{VAGID: g.VAGID, Name: "other"}, "xDuplicateVolumeAccessGroupName"},
+		{"missing VAG", sfapi.ModifyVolumeAccessGroupRequest{VAGID: 9999, Name: "gone"}, "xVolumeAccessGroupIDDoesNotExist"},
+	} {
+		if _, err := c.ModifyVolumeAccessGroup(ctx, &tc.req); sfapi.ErrorName(err) != tc.err {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestDeleteVolumeAccessGroup(t *testing.T) {
+	for _, apply := range []bool{false, true} {
+		fc, c, accountID := newFakeClient(t)
+		c.RetryPolicy = fastRetries
+		ctx := context.Background()
+		g, data, _ := createVAG(t, c, accountID)
+
+		fc.InjectFault(fake.Fault{Method: "DeleteVolumeAccessGroup", Drop: true, Apply: apply, Count: 1})
+		if err := c.DeleteVolumeAccessGroup(ctx, g.VAGID); err != nil {
+			t.Fatalf("apply %t: %v", apply, err)
+		}
+		// Resent only if the group is still there after the lost response
+		calls := 2
+		if apply {
+			calls = 1
+		}
+		if n := fc.Calls("DeleteVolumeAccessGroup"); n != calls {
+			t.Fatalf("apply %t: expected %d attempts, got %d", apply, calls, n)
+		}
+		if _, err := c.GetVolumeAccessGroupByID(ctx, g.VAGID); !sfapi.IsNotFound(err) {
+			t.Fatalf("apply %t: expected the VAG to be gone, got %v", apply, err)
+		}
+		// The volumes themselves stay
+		if v, err := c.GetVolumeByID(ctx, data.VolumeID); err != nil || len(v.VolumeAccessGroups) != 0 {
+			t.Fatalf("apply %t: expected the volume to remain outside any VAG, got %+v, %v", apply, v, err)
+		}
+		if err := c.DeleteVolumeAccessGroup(ctx, g.VAGID); !sfapi.IsNotFound(err) {
+			t.Fatalf("apply %t: expected deleting it again to fail with not found, got %v", apply, err)
+		}
+	}
+}
+
+func TestRemoveVolumesFromVolumeAccessGroup(t *testing.T) {
+	for _, apply := range []bool{false, true} {
+		fc, c, accountID := newFakeClient(t)
+		c.RetryPolicy = fastRetries
+		ctx := context.Background()
+		g, data, logs := createVAG(t, c, accountID)
+
+		fc.InjectFault(fake.Fault{Method: "RemoveVolumesFromVolumeAccessGroup", Drop: true, Apply: apply, Count: 1})
+		if err := c.RemoveVolumesFromVolumeAccessGroup(ctx, g.VAGID, []int64{data.VolumeID}); err != nil {
+			t.Fatalf("apply %t: %v", apply, err)
+		}
+		// Resent only while the volume is still in the group, once it has
+		// been removed resending would fail
+		calls := 2
+		if apply {
+			calls = 1
+		}
+		if n := fc.Calls("RemoveVolumesFromVolumeAccessGroup"); n != calls {
+			t.Fatalf("apply %t: expected %d attempts, got %d", apply, calls, n)
+		}
+		g, err := c.GetVolumeAccessGroupByID(ctx, g.VAGID)
+		if err != nil || len(g.Volumes) != 1 || g.Volumes[0] != logs.VolumeID {
+			t.Fatalf("apply %t: expected only volume %d left, got %+v, %v", apply, logs.VolumeID, g, err)
+		}
+		if err := c.RemoveVolumesFromVolumeAccessGroup(ctx, g.VAGID, []int64{data.VolumeID}); !sfapi.IsNotFound(err) {
+			t.Fatalf("apply %t: expected removing a volume that isn't in the group to fail, got %v", apply, err)
+		}
+	}
+}
+
+func TestRemoveInitiatorsFromVolumeAccessGroup(t *testing.T) {
+	for _, apply := range []bool{false, true} {
+		fc, c, accountID := newFakeClient(t)
+		c.RetryPolicy = fastRetries
+		ctx := context.Background()
+		g, _, _ := createVAG(t, c, accountID)
+
+		fc.InjectFault(fake.Fault{Method: "RemoveInitiatorsFromVolumeAccessGroup", Drop: true, Apply: apply, Count: 1})
+		req := &sfapi.RemoveInitiatorsFromVolumeAccessGroupRequest{VAGID: g.VAGID, Initiators: []string{initiator1}}
+		if err := c.RemoveInitiatorsFromVolumeAccessGroup(ctx, req); err != nil {
+			t.Fatalf("apply %t: %v", apply, err)
+		}
+		calls := 2
+		if apply {
+			calls = 1
+		}
+		if n := fc.Calls("RemoveInitiatorsFromVolumeAccessGroup"); n != calls {
+			t.Fatalf("apply %t: expected %d attempts, got %d", apply, calls, n)
+		}
+		g, err := c.GetVolumeAccessGroupByID(ctx, g.VAGID)
+		if err != nil || len(g.Initiators) != 1 || g.Initiators[0] != initiator2 {
+			t.Fatalf("apply %t: expected only %s left, got %+v, %v", apply, initiator2, g, err)
+		}
+		if err := c.RemoveInitiatorsFromVolumeAccessGroup(ctx, req); sfapi.ErrorName(err) != "xInitiatorDoesNotExist" {
+			t.Fatalf("apply %t: expected removing an initiator that isn't in the group to fail, got %v", apply, err)
+		}
+	}
+}
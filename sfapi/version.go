@@ -0,0 +1,134 @@
+package sfapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SupportedAPIVersions are the Element API versions this package knows how
+// to talk to, oldest first
+var SupportedAPIVersions = []string{"7.0", "8.0", "9.0", "10.0", "11.0", "12.0"}
+
+// capabilities maps optional features to the API version that introduced
+// them, see Client.Supports
+var capabilities = map[string]string{
+	"QoSPolicies":         "10.0",
+	"PurgeDeletedVolumes": "11.0",
+}
+
+// CompareAPIVersions compares two "major.minor" API versions, returning <0,
+// 0 or >0 like strings.Compare
+func CompareAPIVersions(a, b string) int {
+	pa, pb := strings.SplitN(a, ".", 2), strings.SplitN(b, ".", 2)
+	for i := 0; i < 2; i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// endpointVersion returns the API version from a /json-rpc/<version>
+// endpoint, or "" if the endpoint doesn't include one
+func endpointVersion(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) == 2 && parts[0] == "json-rpc" {
+		return parts[1]
+	}
+	return ""
+}
+
+// withEndpointVersion returns endpoint with its path set to
+// /json-rpc/<version>
+func withEndpointVersion(endpoint, version string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/json-rpc/" + version
+	return u.String(), nil
+}
+
+// APIVersion returns the Element API version requests are issued against
+func (c *Client) APIVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.apiVersion
+}
+
+func (c *Client) setAPIVersion(version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiVersion = version
+}
+
+// Supports reports whether the API version in use provides the named
+// feature (ie "QoSPolicies")
+func (c *Client) Supports(feature string) bool {
+	min, ok := capabilities[feature]
+	if !ok {
+		return false
+	}
+	return CompareAPIVersions(c.APIVersion(), min) >= 0
+}
+
+type apiVersionKey struct{}
+
+// atAPIVersion returns a context whose requests are sent to the json-rpc
+// endpoint of version, leaving the version other requests use alone
+func atAPIVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, apiVersionKey{}, version)
+}
+
+// requestVersion returns the API version a request made with ctx is sent to
+func (c *Client) requestVersion(ctx context.Context) string {
+	if version, ok := ctx.Value(apiVersionKey{}).(string); ok {
+		return version
+	}
+	return c.APIVersion()
+}
+
+// NegotiateAPIVersion asks the cluster which API version it runs and
+// switches the client to the highest version supported by both sides.
+func (c *Client) NegotiateAPIVersion(ctx context.Context) error {
+	// Ask using the oldest version we support, the version configured in
+	// the endpoint may well be newer than what the cluster understands.
+	// Only this request is sent there, others made meanwhile (ie by the
+	// daemon) keep using the current version.
+	info, err := c.GetClusterVersionInfo(atAPIVersion(ctx, SupportedAPIVersions[0]))
+	if err != nil {
+		return fmt.Errorf("unable to determine API version of cluster at %s: %w", mvipHost(c.activeEndpoint()), err)
+	}
+	clusterVersion := info.Result.ClusterAPIVersion
+
+	version := ""
+	for _, v := range SupportedAPIVersions {
+		if CompareAPIVersions(v, clusterVersion) <= 0 {
+			version = v
+		}
+	}
+	if version == "" {
+		return fmt.Errorf("cluster at %s supports API version %s, but this driver requires %s or later",
+			mvipHost(c.activeEndpoint()), clusterVersion, SupportedAPIVersions[0])
+	}
+
+	log.Debugf("Using Element API version %s (cluster %s supports up to %s)", version, info.Result.ClusterVersion, clusterVersion)
+	c.setAPIVersion(version)
+	return nil
+}
@@ -0,0 +1,127 @@
+package sfapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// versionStub reports clusterAPIVersion from GetClusterVersionInfo, holding
+// the response until Release is called, and records the API version each
+// request was sent to
+type versionStub struct {
+	mu       sync.Mutex
+	versions map[string][]string
+	cluster  string
+	release  chan struct{}
+	once     sync.Once
+}
+
+func (s *versionStub) Release() {
+	s.once.Do(func() { close(s.release) })
+}
+
+func (s *versionStub) Versions(method string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.versions[method]...)
+}
+
+func (s *versionStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+		ID     int    `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.versions[req.Method] = append(s.versions[req.Method], strings.TrimPrefix(r.URL.Path, "/json-rpc/"))
+	s.mu.Unlock()
+
+	result := map[string]interface{}{}
+	if req.Method == "GetClusterVersionInfo" {
+		<-s.release
+		result = map[string]interface{}{"clusterAPIVersion": s.cluster, "clusterVersion": s.cluster + ".0"}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "result": result})
+}
+
+// newVersionStub starts a versionStub and returns it along with a client
+// configured for version
+func newVersionStub(t *testing.T, cluster, version string) (*versionStub, *Client) {
+	t.Helper()
+	s := &versionStub{versions: map[string][]string{}, cluster: cluster, release: make(chan struct{})}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	t.Cleanup(s.Release)
+	c, err := New(Config{EndPoint: srv.URL + "/json-rpc/" + version, Retry: fastRetries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	for _, tc := range []struct {
+		cluster, version, err string
+	}{
+		{"12.0", "12.0", ""},
+		{"9.5", "9.0", ""},
+		{"13.0", "12.0", ""},
+		{"6.0", "11.0", "supports API version 6.0, but this driver requires 7.0 or later"},
+	} {
+		s, c := newVersionStub(t, tc.cluster, "11.0")
+		s.Release()
+		err := c.NegotiateAPIVersion(context.Background())
+		if tc.err == "" && err != nil {
+			t.Errorf("cluster %s: %v", tc.cluster, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("cluster %s: expected an error containing %q, got %v", tc.cluster, tc.err, err)
+		}
+		if v := c.APIVersion(); v != tc.version {
+			t.Errorf("cluster %s: expected API version %s, got %s", tc.cluster, tc.version, v)
+		}
+		if v := s.Versions("GetClusterVersionInfo"); len(v) != 1 || v[0] != SupportedAPIVersions[0] {
+			t.Errorf("cluster %s: expected the version to be asked for at %s, got %v", tc.cluster, SupportedAPIVersions[0], v)
+		}
+	}
+}
+
+func TestNegotiateLeavesOtherRequestsAlone(t *testing.T) {
+	s, c := newVersionStub(t, "12.0", "10.0")
+	ctx := context.Background()
+	negotiated := make(chan error, 1)
+	go func() {
+		negotiated <- c.NegotiateAPIVersion(ctx)
+	}()
+	for len(s.Versions("GetClusterVersionInfo")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// While negotiation is in progress other requests still go to the
+	// configured version
+	if _, err := c.Request(ctx, "ListActiveVolumes", nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Versions("ListActiveVolumes"); len(v) != 1 || v[0] != "10.0" {
+		t.Fatalf("expected the request to be sent to 10.0 during negotiation, got %v", v)
+	}
+	s.Release()
+	if err := <-negotiated; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Request(ctx, "ListActiveVolumes", nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Versions("ListActiveVolumes"); len(v) != 2 || v[1] != "12.0" {
+		t.Fatalf("expected the next request to be sent to 12.0, got %v", v)
+	}
+}
@@ -1,138 +1,254 @@
 package sfapi
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"strings"
-	"time"
 )
 
-func (c *Client) ListVolumesForAccount(listReq *ListVolumesForAccountRequest) (volumes []Volume, err error) {
-	response, err := c.Request("ListVolumesForAccount", listReq, newReqID())
+func (c *Client) ListVolumesForAccount(ctx context.Context, listReq *ListVolumesForAccountRequest) (volumes []Volume, err error) {
+	response, err := c.Request(ctx, "ListVolumesForAccount", listReq, newReqID())
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 	var result ListVolumesResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	if err := decodeResponse("ListVolumesForAccount", response, &result); err != nil {
 		return nil, err
 	}
 	volumes = result.Result.Volumes
 	return volumes, err
 }
 
-func (c *Client) GetVolumeByID(volID int64) (v Volume, err error) {
+func (c *Client) GetVolumeByID(ctx context.Context, volID int64) (v Volume, err error) {
 	var req ListActiveVolumesRequest
 	req.StartVolumeID = volID
 	req.Limit = 1
-	volumes, err := c.ListActiveVolumes(&req)
+	volumes, err := c.ListActiveVolumes(ctx, &req)
 	if err != nil {
 		return v, err
 	}
-	if len(volumes) < 1 {
-		return Volume{}, fmt.Errorf("Failed to find volume with ID: %d", volID)
+	// The listing starts at volID, so if that volume is gone we get
+	// whatever active volume comes after it
+	if len(volumes) < 1 || volumes[0].VolumeID != volID {
+		return Volume{}, fmt.Errorf("Failed to find volume with ID %d: %w", volID, ErrNotFound)
 	}
 	return volumes[0], nil
 }
 
-func (c *Client) GetVolumeByName(n string, acctID int64) (v Volume, err error) {
-	vols, err := c.GetVolumesByName(n, acctID)
+func (c *Client) GetVolumeByName(ctx context.Context, n string, acctID int64) (v Volume, err error) {
+	vols, err := c.GetVolumesByName(ctx, n, acctID)
 	if err == nil && len(vols) == 1 {
 		return vols[0], nil
 	}
 
 	if len(vols) > 1 {
 		err = fmt.Errorf("Found more than one Volume with Name: %s for Account: %d", n, acctID)
-	} else if len(vols) < 1 {
-		err = fmt.Errorf("Failed to find any Volumes with Name: %s for Account: %d", n, acctID)
+	} else if len(vols) < 1 && err == nil {
+		err = fmt.Errorf("Failed to find any Volumes with Name: %s for Account %d: %w", n, acctID, ErrNotFound)
 	}
 	return v, err
 }
 
-func (c *Client) GetVolumesByName(sfName string, acctID int64) (v []Volume, err error) {
-	var listReq ListVolumesForAccountRequest
+func (c *Client) GetVolumesByName(ctx context.Context, sfName string, acctID int64) (v []Volume, err error) {
 	var foundVolumes []Volume
-	listReq.AccountID = acctID
-	volumes, err := c.ListVolumesForAccount(&listReq)
-	if err != nil {
-		log.Error("Error retrieving volumes: ", err)
-		return foundVolumes, err
-	}
-	for _, vol := range volumes {
+	it := c.ListVolumesForAccountIter(ctx, acctID, 0)
+	for it.Next() {
+		vol := it.Volume()
 		if vol.Name == sfName && vol.Status == "active" {
 			foundVolumes = append(foundVolumes, vol)
 		}
 	}
+	if err := it.Err(); err != nil {
+		log.Error("Error retrieving volumes: ", err)
+		return foundVolumes, err
+	}
 	if len(foundVolumes) > 1 {
 		log.Warningf("Found more than one volume with the name: %s\n%+v", sfName, foundVolumes)
 	}
 	if len(foundVolumes) == 0 {
-		return foundVolumes, fmt.Errorf("Failed to find any volumes by the name of: %s for this account: %d", sfName, acctID)
+		return foundVolumes, fmt.Errorf("Failed to find any volumes by the name of: %s for this account %d: %w", sfName, acctID, ErrNotFound)
 	}
 	return foundVolumes, nil
 }
 
-func (c *Client) ListActiveVolumes(listVolReq *ListActiveVolumesRequest) (volumes []Volume, err error) {
-	response, err := c.Request("ListActiveVolumes", listVolReq, newReqID())
+func (c *Client) ListActiveVolumes(ctx context.Context, listVolReq *ListActiveVolumesRequest) (volumes []Volume, err error) {
+	response, err := c.Request(ctx, "ListActiveVolumes", listVolReq, newReqID())
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 	var result ListVolumesResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	if err := decodeResponse("ListActiveVolumes", response, &result); err != nil {
 		return nil, err
 	}
 	volumes = result.Result.Volumes
 	return volumes, err
 }
 
-func (c *Client) CloneVolume(req *CloneVolumeRequest) (vol Volume, err error) {
-	response, err := c.Request("CloneVolume", req, newReqID())
+func (c *Client) CloneVolume(ctx context.Context, req *CloneVolumeRequest) (vol Volume, err error) {
+	return c.CloneVolumeWithProgress(ctx, req, nil)
+}
+
+// CloneVolumeWithProgress clones a volume and waits for the cluster to
+// finish the clone, reporting progress along the way
+func (c *Client) CloneVolumeWithProgress(ctx context.Context, req *CloneVolumeRequest, progress ProgressFunc) (vol Volume, err error) {
+	response, err := c.Request(ctx, "CloneVolume", req, newReqID())
+	if err != nil {
+		return Volume{}, err
+	}
 	var result CloneVolumeResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	if err := decodeResponse("CloneVolume", response, &result); err != nil {
 		return Volume{}, err
 	}
+	if _, err := c.WaitForAsync(ctx, result.Result.AsyncHandle, progress); err != nil {
+		return Volume{}, err
+	}
+	return c.GetVolumeByID(ctx, result.Result.VolumeID)
+}
+
+// CopyVolume overwrites the contents of an existing volume with those of
+// another (or of one of its snapshots), returning once the copy completes
+func (c *Client) CopyVolume(ctx context.Context, req *CopyVolumeRequest, progress ProgressFunc) (err error) {
+	response, err := c.Request(ctx, "CopyVolume", req, newReqID())
+	if err != nil {
+		return err
+	}
+	var result CopyVolumeResult
+	if err := decodeResponse("CopyVolume", response, &result); err != nil {
+		return err
+	}
+	_, err = c.WaitForAsync(ctx, result.Result.AsyncHandle, progress)
+	return err
+}
 
-	wait := 0
-	multiplier := 1
-	for wait < 10 {
-		wait += wait
-		vol, err = c.GetVolumeByID(result.Result.VolumeID)
-		if err == nil {
-			break
+// CloneMultipleVolumes clones a set of volumes at the same point in time,
+// returning the new volumes once the cluster has finished all of them
+func (c *Client) CloneMultipleVolumes(ctx context.Context, req *CloneMultipleVolumesRequest, progress ProgressFunc) (vols []Volume, err error) {
+	response, err := c.Request(ctx, "CloneMultipleVolumes", req, newReqID())
+	if err != nil {
+		return nil, err
+	}
+	var result CloneMultipleVolumesResult
+	if err := decodeResponse("CloneMultipleVolumes", response, &result); err != nil {
+		return nil, err
+	}
+	if _, err := c.WaitForAsync(ctx, result.Result.AsyncHandle, progress); err != nil {
+		return nil, err
+	}
+	for _, m := range result.Result.Members {
+		v, err := c.GetVolumeByID(ctx, m.VolumeID)
+		if err != nil {
+			return vols, err
 		}
-		time.Sleep(time.Second * time.Duration(multiplier))
-		multiplier *= wait
+		vols = append(vols, v)
 	}
-	return
+	return vols, nil
 }
 
-func (c *Client) CreateVolume(createReq *CreateVolumeRequest) (vol Volume, err error) {
-	response, err := c.Request("CreateVolume", createReq, newReqID())
+func (c *Client) CreateVolume(ctx context.Context, createReq *CreateVolumeRequest) (vol Volume, err error) {
+	// CreateVolume isn't idempotent, so if a retry is needed after an
+	// ambiguous failure first check whether the volume was created anyway.
+	// Names aren't unique, only a volume that wasn't there before the first
+	// attempt counts, without that list nothing is resent.
+	var applied func() bool
+	var existing Volume
+	if before, err := c.GetVolumesByName(ctx, createReq.Name, createReq.AccountID); err == nil || IsNotFound(err) {
+		known := map[int64]bool{}
+		for _, v := range before {
+			known[v.VolumeID] = true
+		}
+		applied = func() bool {
+			vols, err := c.GetVolumesByName(ctx, createReq.Name, createReq.AccountID)
+			if err != nil {
+				return false
+			}
+			for _, v := range vols {
+				if !known[v.VolumeID] {
+					existing = v
+					return true
+				}
+			}
+			return false
+		}
+	}
+	response, done, err := c.requestWithRetry(ctx, "CreateVolume", createReq, newReqID(), applied)
 	if err != nil {
 		return Volume{}, err
 	}
+	if done {
+		return existing, nil
+	}
 	var result CreateVolumeResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Fatal(err)
+	if err := decodeResponse("CreateVolume", response, &result); err != nil {
 		return Volume{}, err
 	}
 
-	vol, err = c.GetVolumeByID(result.Result.VolumeID)
+	vol, err = c.GetVolumeByID(ctx, result.Result.VolumeID)
 	return
 }
 
-func (c *Client) AddVolumeToAccessGroup(groupID int64, volIDs []int64) (err error) {
+// VolumeAccessModes are the valid values of a volume's access setting
+var VolumeAccessModes = []string{"readWrite", "readOnly", "locked", "replicationTarget"}
+
+func validAccessMode(access string) bool {
+	for _, a := range VolumeAccessModes {
+		if a == access {
+			return true
+		}
+	}
+	return false
+}
+
+// ModifyVolume changes the size, QoS, access mode or account of an existing
+// volume, only the fields set in the request are changed.  Volumes can only
+// grow, a TotalSize smaller than the current size is rejected.
+func (c *Client) ModifyVolume(ctx context.Context, req *ModifyVolumeRequest) (vol Volume, err error) {
+	if req.Access != "" && !validAccessMode(req.Access) {
+		return Volume{}, fmt.Errorf("invalid access mode %q, expected one of %s", req.Access, strings.Join(VolumeAccessModes, ", "))
+	}
+	if req.TotalSize != 0 {
+		current, err := c.GetVolumeByID(ctx, req.VolumeID)
+		if err != nil {
+			return Volume{}, err
+		}
+		if req.TotalSize < current.TotalSize {
+			err = fmt.Errorf("volume %d is %d bytes, it can't be shrunk to %d bytes", req.VolumeID, current.TotalSize, req.TotalSize)
+			log.Error(err)
+			return Volume{}, err
+		}
+	}
+
+	// Inline QoS only takes effect once the volume is detached from any
+	// policy it uses
+	if req.Qos != nil && req.QoSPolicyID == 0 && req.AssociateWithQoSPolicy == nil && c.Supports("QoSPolicies") {
+		associate := false
+		req.AssociateWithQoSPolicy = &associate
+	}
+
+	response, err := c.Request(ctx, "ModifyVolume", req, newReqID())
+	if err != nil {
+		return Volume{}, err
+	}
+	var result ModifyVolumeResult
+	if err := decodeResponse("ModifyVolume", response, &result); err != nil {
+		return Volume{}, err
+	}
+	// Clusters before 9.0 don't return the modified volume
+	if result.Result.Volume.VolumeID == req.VolumeID {
+		return result.Result.Volume, nil
+	}
+	return c.GetVolumeByID(ctx, req.VolumeID)
+}
+
+func (c *Client) AddVolumeToAccessGroup(ctx context.Context, groupID int64, volIDs []int64) (err error) {
 	req := &AddVolumesToVolumeAccessGroupRequest{
 		VolumeAccessGroupID: groupID,
 		Volumes:             volIDs,
 	}
-	_, err = c.Request("AddVolumesToVolumeAccessGroup", req, newReqID())
+	_, err = c.Request(ctx, "AddVolumesToVolumeAccessGroup", req, newReqID())
 	if err != nil {
 		log.Error("Failed to add volume(s) to VAG %d: ", groupID)
 		return err
@@ -140,19 +256,18 @@ func (c *Client) AddVolumeToAccessGroup(groupID int64, volIDs []int64) (err erro
 	return err
 }
 
-func (c *Client) DeleteRange(startID, endID int64) {
-	idx := startID
-	for idx < endID {
-		c.DeleteVolume(idx)
+func (c *Client) DeleteRange(ctx context.Context, startID, endID int64) {
+	for idx := startID; idx <= endID; idx++ {
+		c.DeleteVolume(ctx, idx)
 	}
 	return
 }
 
-func (c *Client) DeleteVolume(volumeID int64) (err error) {
+func (c *Client) DeleteVolume(ctx context.Context, volumeID int64) (err error) {
 	// TODO(jdg): Add options like purge=True|False, range, ALL etc
 	var req DeleteVolumeRequest
 	req.VolumeID = volumeID
-	_, err = c.Request("DeleteVolume", req, newReqID())
+	_, err = c.Request(ctx, "DeleteVolume", req, newReqID())
 	if err != nil {
 		log.Error("Failed to delete volume ID: ", volumeID)
 		return err
@@ -160,7 +275,7 @@ func (c *Client) DeleteVolume(volumeID int64) (err error) {
 	return
 }
 
-func (c *Client) DetachVolume(v Volume) (err error) {
+func (c *Client) DetachVolume(ctx context.Context, v Volume) (err error) {
 	if c.SVIP == "" {
 		err = errors.New("Unable to perform iSCSI actions without setting SVIP")
 		return
@@ -170,11 +285,11 @@ func (c *Client) DetachVolume(v Volume) (err error) {
 		Portal: c.SVIP,
 		Iqn:    v.Iqn,
 	}
-	err = iscsiDisableDelete(tgt)
+	err = iscsiDisableDelete(ctx, tgt)
 	return
 }
 
-func (c *Client) AttachVolume(v *Volume, iface string) (path, device string, err error) {
+func (c *Client) AttachVolume(ctx context.Context, v *Volume, iface string) (path, device string, err error) {
 	var req GetAccountByIDRequest
 	path = "/dev/disk/by-path/ip-" + c.SVIP + "-iscsi-" + v.Iqn + "-lun-0"
 
@@ -191,7 +306,7 @@ func (c *Client) AttachVolume(v *Volume, iface string) (path, device string, err
 	}
 
 	req.AccountID = v.AccountID
-	a, err := c.GetAccountByID(&req)
+	a, err := c.GetAccountByID(ctx, &req)
 	if err != nil {
 		log.Error("Failed to get account ", v.AccountID, ": ", err)
 		return path, device, err
@@ -204,7 +319,7 @@ func (c *Client) AttachVolume(v *Volume, iface string) (path, device string, err
 		return path, device, nil
 	}
 
-	err = LoginWithChap(v.Iqn, c.SVIP, a.Username, a.InitiatorSecret, iface)
+	err = LoginWithChap(v.Iqn, c.SVIP, a.Username, string(a.InitiatorSecret), iface)
 	if err != nil {
 		log.Error(err)
 		return path, device, err
@@ -215,3 +330,111 @@ func (c *Client) AttachVolume(v *Volume, iface string) (path, device string, err
 	}
 	return path, device, nil
 }
+
+// ListDeletedVolumes returns the volumes that have been deleted but not yet
+// purged, these can still be restored with RestoreDeletedVolume
+func (c *Client) ListDeletedVolumes(ctx context.Context) (volumes []Volume, err error) {
+	var req ListDeletedVolumesRequest
+	response, err := c.Request(ctx, "ListDeletedVolumes", &req, newReqID())
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	var result ListVolumesResult
+	if err := decodeResponse("ListDeletedVolumes", response, &result); err != nil {
+		return nil, err
+	}
+	return result.Result.Volumes, nil
+}
+
+// GetDeletedVolumeByID returns a deleted volume that hasn't been purged yet
+func (c *Client) GetDeletedVolumeByID(ctx context.Context, volID int64) (v Volume, err error) {
+	volumes, err := c.ListDeletedVolumes(ctx)
+	if err != nil {
+		return v, err
+	}
+	for _, vol := range volumes {
+		if vol.VolumeID == volID {
+			return vol, nil
+		}
+	}
+	return v, fmt.Errorf("Failed to find deleted volume with ID %d: %w", volID, ErrNotFound)
+}
+
+// RestoreDeletedVolume makes a deleted volume active again, it keeps its
+// name, account and contents but isn't added back to any access groups
+func (c *Client) RestoreDeletedVolume(ctx context.Context, volumeID int64) (vol Volume, err error) {
+	applied := func() bool {
+		v, err := c.GetVolumeByID(ctx, volumeID)
+		if err != nil {
+			return false
+		}
+		vol = v
+		return true
+	}
+	req := RestoreDeletedVolumeRequest{VolumeID: volumeID}
+	_, done, err := c.requestWithRetry(ctx, "RestoreDeletedVolume", &req, newReqID(), applied)
+	if err != nil {
+		log.Error("Failed to restore volume ID: ", volumeID)
+		return Volume{}, err
+	}
+	if done {
+		return vol, nil
+	}
+	return c.GetVolumeByID(ctx, volumeID)
+}
+
+// PurgeDeletedVolume permanently removes a deleted volume, after which it
+// can't be restored
+func (c *Client) PurgeDeletedVolume(ctx context.Context, volumeID int64) (err error) {
+	applied := func() bool {
+		_, err := c.GetDeletedVolumeByID(ctx, volumeID)
+		return IsNotFound(err)
+	}
+	req := PurgeDeletedVolumeRequest{VolumeID: volumeID}
+	_, _, err = c.requestWithRetry(ctx, "PurgeDeletedVolume", &req, newReqID(), applied)
+	if err != nil {
+		log.Error("Failed to purge volume ID: ", volumeID)
+		return err
+	}
+	return nil
+}
+
+// PurgeDeletedVolumes permanently removes the given deleted volumes.
+// Clusters before 11.0 lack the bulk call, so they're purged one at a time.
+func (c *Client) PurgeDeletedVolumes(ctx context.Context, volumeIDs []int64) (err error) {
+	if len(volumeIDs) == 0 {
+		return nil
+	}
+	if !c.Supports("PurgeDeletedVolumes") {
+		for _, id := range volumeIDs {
+			if err := c.PurgeDeletedVolume(ctx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Purging is idempotent apart from volumes that are already gone, which
+	// a resend would fail on, so check what's left before resending
+	applied := func() bool {
+		deleted, err := c.ListDeletedVolumes(ctx)
+		if err != nil {
+			return false
+		}
+		for _, v := range deleted {
+			for _, id := range volumeIDs {
+				if v.VolumeID == id {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	req := PurgeDeletedVolumesRequest{VolumeIDs: volumeIDs}
+	_, _, err = c.requestWithRetry(ctx, "PurgeDeletedVolumes", &req, newReqID(), applied)
+	if err != nil {
+		log.Errorf("Failed to purge volumes %v: %v", volumeIDs, err)
+		return err
+	}
+	return nil
+}
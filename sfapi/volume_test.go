@@ -0,0 +1,289 @@
+package sfapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+	"github.com/solidfire/solidfire-docker-driver/sfapi/fake"
+)
+
+func TestCreateVolume(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	v, err := c.CreateVolume(ctx, &sfapi.CreateVolumeRequest{
+		Name:      "data",
+		AccountID: accountID,
+		TotalSize: 2 << 30,
+		Qos:       &sfapi.QoS{MinIOPS: 100, MaxIOPS: 1000, BurstIOPS: 2000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "data" || v.AccountID != accountID || v.TotalSize != 2<<30 || v.Status != "active" {
+		t.Fatalf("unexpected volume %+v", v)
+	}
+	if v.Qos.MaxIOPS != 1000 {
+		t.Fatalf("expected maxIOPS 1000, got %d", v.Qos.MaxIOPS)
+	}
+	if n := len(fc.Volumes()); n != 1 {
+		t.Fatalf("expected 1 volume on the cluster, got %d", n)
+	}
+	if _, err := c.CreateVolume(ctx, &sfapi.CreateVolumeRequest{Name: "bad", AccountID: accountID}); err == nil {
+		t.Fatal("expected creating a volume with no size to fail")
+	}
+	if _, err := c.CreateVolume(ctx, &sfapi.CreateVolumeRequest{Name: "orphan", AccountID: 999, TotalSize: 1 << 30}); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected a not found error for an unknown account, got %v", err)
+	}
+}
+
+func TestListVolumes(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	other, err := c.AddAccount(ctx, &sfapi.AddAccountRequest{Username: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		createVolume(t, c, accountID, name)
+	}
+	createVolume(t, c, other, "d")
+	fc.AddVolume(sfapi.Volume{Name: "gone", AccountID: accountID, Status: "deleted"})
+
+	active, err := c.ListActiveVolumes(ctx, &sfapi.ListActiveVolumesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 4 {
+		t.Fatalf("expected 4 active volumes, got %d", len(active))
+	}
+	mine, err := c.ListVolumesForAccount(ctx, &sfapi.ListVolumesForAccountRequest{AccountID: accountID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 4 {
+		t.Fatalf("expected 4 volumes for the account including the deleted one, got %d", len(mine))
+	}
+
+	var names []string
+	it := c.ListVolumesForAccountIter(ctx, accountID, 2)
+	for it.Next() {
+		if it.Volume().Status == "active" {
+			names = append(names, it.Volume().Name)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || names[0] != "a" || names[2] != "c" {
+		t.Fatalf("expected volumes a, b and c from the iterator, got %v", names)
+	}
+	if calls := fc.Calls("ListVolumesForAccount"); calls < 3 {
+		t.Fatalf("expected the iterator to page, only %d requests were made", calls)
+	}
+
+	v, err := c.GetVolumeByName(ctx, "b", accountID)
+	if err != nil || v.Name != "b" {
+		t.Fatalf("GetVolumeByName: %+v, %v", v, err)
+	}
+	if _, err := c.GetVolumeByName(ctx, "d", accountID); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected another account's volume not to be found, got %v", err)
+	}
+}
+
+func TestRemoveVolume(t *testing.T) {
+	_, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	v := createVolume(t, c, accountID, "data")
+	if err := c.DeleteVolume(ctx, v.VolumeID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetVolumeByID(ctx, v.VolumeID); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected a not found error for a deleted volume, got %v", err)
+	}
+	deleted, err := c.GetDeletedVolumeByID(ctx, v.VolumeID)
+	if err != nil || deleted.Status != "deleted" {
+		t.Fatalf("GetDeletedVolumeByID: %+v, %v", deleted, err)
+	}
+	if err := c.DeleteVolume(ctx, v.VolumeID); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected deleting twice to fail with not found, got %v", err)
+	}
+
+	restored, err := c.RestoreDeletedVolume(ctx, v.VolumeID)
+	if err != nil || restored.Status != "active" {
+		t.Fatalf("RestoreDeletedVolume: %+v, %v", restored, err)
+	}
+	if err := c.DeleteVolume(ctx, v.VolumeID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PurgeDeletedVolume(ctx, v.VolumeID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetDeletedVolumeByID(ctx, v.VolumeID); !sfapi.IsNotFound(err) {
+		t.Fatalf("expected a purged volume to be gone, got %v", err)
+	}
+}
+
+func TestCloneWaitsForAsync(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	v := createVolume(t, c, accountID, "data")
+
+	var progress []int
+	clone, err := c.CloneVolumeWithProgress(ctx, &sfapi.CloneVolumeRequest{VolumeID: v.VolumeID, Name: "copy"},
+		func(r sfapi.AsyncResult) {
+			if pct, ok := r.PercentComplete(); ok {
+				progress = append(progress, pct)
+			}
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.Name != "copy" || clone.VolumeID == v.VolumeID {
+		t.Fatalf("unexpected clone %+v", clone)
+	}
+	if fc.Calls("GetAsyncResult") != fc.AsyncPolls+1 {
+		t.Fatalf("expected %d polls, got %d", fc.AsyncPolls+1, fc.Calls("GetAsyncResult"))
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 100 {
+		t.Fatalf("expected progress ending at 100%%, got %v", progress)
+	}
+}
+
+func TestAsyncFailure(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	fc.AsyncPolls = 0
+	ctx := context.Background()
+	src := createVolume(t, c, accountID, "src")
+	dst := createVolume(t, c, accountID, "dst")
+
+	response, err := c.Request(ctx, "CopyVolume", &sfapi.CopyVolumeRequest{VolumeID: src.VolumeID, DstVolumeID: dst.VolumeID}, sfapi.NewReqID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result sfapi.CopyVolumeResult
+	if err := json.Unmarshal(response, &result); err != nil {
+		t.Fatal(err)
+	}
+	fc.FailAsync(result.Result.AsyncHandle, &sfapi.APIError{Code: 500, Name: "xSliceFailure", Message: "copy failed"})
+	_, err = c.WaitForAsync(ctx, result.Result.AsyncHandle, nil)
+	if sfapi.ErrorName(err) != "xSliceFailure" {
+		t.Fatalf("expected the async operation's error, got %v", err)
+	}
+}
+
+func TestInjectedFaults(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	ctx := context.Background()
+	v := createVolume(t, c, accountID, "data")
+
+	fc.InjectFault(fake.Fault{Method: "DeleteVolume", Err: &sfapi.APIError{Code: 500, Name: "xPermissionDenied", Message: "denied"}, Count: 1})
+	err := c.DeleteVolume(ctx, v.VolumeID)
+	if sfapi.ErrorName(err) != "xPermissionDenied" {
+		t.Fatalf("expected the injected error, got %v", err)
+	}
+	var apiErr *sfapi.APIError
+	if !errors.As(err, &apiErr) || apiErr.Method != "DeleteVolume" {
+		t.Fatalf("expected an *APIError for DeleteVolume, got %#v", err)
+	}
+	if err := c.DeleteVolume(ctx, v.VolumeID); err != nil {
+		t.Fatalf("expected the fault to apply once, got %v", err)
+	}
+
+	// A fault that only delays the response carries out the request once
+	fc.InjectFault(fake.Fault{Method: "CreateVolume", Latency: time.Millisecond, Apply: true, Count: 1})
+	createVolume(t, c, accountID, "slow")
+	if vols := fc.Volumes(); len(vols) != 2 || vols[1].Name != "slow" {
+		t.Fatalf("expected the delayed create to make one volume, got %+v", vols)
+	}
+
+	fc.InjectFault(fake.Fault{Drop: true})
+	if _, err := c.ListActiveVolumes(ctx, &sfapi.ListActiveVolumesRequest{}); err == nil || sfapi.ErrorName(err) != "" {
+		t.Fatalf("expected a connection error, got %v", err)
+	}
+	fc.ClearFaults()
+	if _, err := c.ListActiveVolumes(ctx, &sfapi.ListActiveVolumesRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fastRetries keeps the delay between retries short
+var fastRetries = sfapi.RetryPolicy{MaxAttempts: 3, InitialDelayMs: 1, MaxDelayMs: 2}
+
+func TestCreateResentWhenNotApplied(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	c.RetryPolicy = fastRetries
+	fc.InjectFault(fake.Fault{Method: "CreateVolume", Drop: true, Count: 1})
+	v := createVolume(t, c, accountID, "data")
+	if n := fc.Calls("CreateVolume"); n != 2 {
+		t.Fatalf("expected CreateVolume to be resent once, got %d attempts", n)
+	}
+	if vols := fc.Volumes(); len(vols) != 1 || vols[0].VolumeID != v.VolumeID {
+		t.Fatalf("expected exactly the one volume, got %+v", vols)
+	}
+}
+
+func TestCreateNotResentWhenApplied(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	c.RetryPolicy = fastRetries
+	fc.InjectFault(fake.Fault{Method: "CreateVolume", Drop: true, Apply: true, Count: 1})
+	v := createVolume(t, c, accountID, "data")
+	if n := fc.Calls("CreateVolume"); n != 1 {
+		t.Fatalf("expected CreateVolume not to be resent, got %d attempts", n)
+	}
+	if vols := fc.Volumes(); len(vols) != 1 || vols[0].VolumeID != v.VolumeID || v.Name != "data" {
+		t.Fatalf("expected the volume created by the lost request, got %+v and %+v", v, vols)
+	}
+}
+
+func TestCreateAppliedIgnoresExistingVolume(t *testing.T) {
+	for _, apply := range []bool{false, true} {
+		fc, c, accountID := newFakeClient(t)
+		c.RetryPolicy = fastRetries
+		old := createVolume(t, c, accountID, "data")
+		fc.InjectFault(fake.Fault{Method: "CreateVolume", Drop: true, Apply: apply, Count: 1})
+		v := createVolume(t, c, accountID, "data")
+		// The volume that was already there doesn't show the lost request
+		// was carried out
+		calls := 3
+		if apply {
+			calls = 2
+		}
+		if n := fc.Calls("CreateVolume"); n != calls {
+			t.Fatalf("apply %t: expected %d CreateVolume attempts, got %d", apply, calls, n)
+		}
+		if vols := fc.Volumes(); len(vols) != 2 || v.VolumeID == old.VolumeID || vols[1].VolumeID != v.VolumeID {
+			t.Fatalf("apply %t: expected a second volume distinct from %d, got %+v and %+v", apply, old.VolumeID, v, vols)
+		}
+	}
+}
+
+func TestCreateNotResentWithoutExistingList(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	c.RetryPolicy = fastRetries
+	fc.InjectFault(fake.Fault{Method: "ListVolumesForAccount", Err: &sfapi.APIError{Code: 500, Name: "xUnknown", Message: "boom"}, Count: 1})
+	fc.InjectFault(fake.Fault{Method: "CreateVolume", Drop: true, Count: 1})
+	if _, err := c.CreateVolume(context.Background(), &sfapi.CreateVolumeRequest{Name: "data", AccountID: accountID, TotalSize: 1 << 30}); err == nil {
+		t.Fatal("expected the dropped CreateVolume to fail")
+	}
+	if n := fc.Calls("CreateVolume"); n != 1 {
+		t.Fatalf("expected CreateVolume not to be resent, got %d attempts", n)
+	}
+}
+
+func TestModifyVolumeResent(t *testing.T) {
+	fc, c, accountID := newFakeClient(t)
+	c.RetryPolicy = fastRetries
+	v := createVolume(t, c, accountID, "data")
+	fc.InjectFault(fake.Fault{Method: "ModifyVolume", Drop: true, Apply: true, Count: 1})
+	v, err := c.ModifyVolume(context.Background(), &sfapi.ModifyVolumeRequest{VolumeID: v.VolumeID, Access: "readOnly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fc.Calls("ModifyVolume"); n != 2 || v.Access != "readOnly" {
+		t.Fatalf("expected ModifyVolume to be resent once, got %d attempts and access %s", n, v.Access)
+	}
+}
@@ -0,0 +1,253 @@
+package sfcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+var (
+	accountCmd = cli.Command{
+		Name:   "account",
+		Usage:  "account related commands",
+		Before: negotiateAPIVersion,
+		Subcommands: []cli.Command{
+			accountCreateCmd,
+			accountListCmd,
+			accountShowCmd,
+			accountModifyCmd,
+			accountDeleteCmd,
+		},
+	}
+
+	accountCreateCmd = cli.Command{
+		Name:  "create",
+		Usage: "create a new account: `create [options] NAME`",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "initiator-secret",
+				Usage: "CHAP initiator secret, generated by the cluster if not set: `[--initiator-secret <secret>]`",
+			},
+			cli.StringFlag{
+				Name:  "target-secret",
+				Usage: "CHAP target secret, generated by the cluster if not set: `[--target-secret <secret>]`",
+			},
+			cli.StringFlag{
+				Name:  "attributes",
+				Usage: "JSON object of attributes to set on the account: `[--attributes '{\"team\": \"web\"}']`",
+			},
+		},
+		Action: cmdAccountCreate,
+	}
+
+	accountListCmd = cli.Command{
+		Name:   "list",
+		Usage:  "list existing accounts: `list`",
+		Action: cmdAccountList,
+	}
+
+	accountShowCmd = cli.Command{
+		Name:  "show",
+		Usage: "show the details and efficiency of an account: `show ACCOUNT`",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "show-secrets",
+				Usage: "include the account's CHAP secrets: `[--show-secrets]`",
+			},
+		},
+		Action: cmdAccountShow,
+	}
+
+	accountModifyCmd = cli.Command{
+		Name:  "modify",
+		Usage: "modify an existing account: `modify [options] ACCOUNT`",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "status",
+				Usage: "account status, locking an account disconnects its volumes: `[--status active|locked]`",
+			},
+			cli.StringFlag{
+				Name:  "initiator-secret",
+				Usage: "new CHAP initiator secret: `[--initiator-secret <secret>]`",
+			},
+			cli.StringFlag{
+				Name:  "target-secret",
+				Usage: "new CHAP target secret: `[--target-secret <secret>]`",
+			},
+			cli.StringFlag{
+				Name:  "attributes",
+				Usage: "JSON object replacing the account's attributes: `[--attributes '{\"team\": \"web\"}']`",
+			},
+		},
+		Action: cmdAccountModify,
+	}
+
+	accountDeleteCmd = cli.Command{
+		Name:   "delete",
+		Usage:  "delete an account, its volumes must be deleted and purged first: `delete ACCOUNT`",
+		Action: cmdAccountDelete,
+	}
+)
+
+func parseAttributes(s string) (attrs map[string]interface{}, err error) {
+	if s == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(s), &attrs); err != nil {
+		return nil, fmt.Errorf("invalid attributes %q, expected a JSON object: %v", s, err)
+	}
+	return attrs, nil
+}
+
+func printAccount(a sfapi.Account, showSecrets bool) {
+	fmt.Println("-------------------------------------------")
+	fmt.Println("ID:              ", a.AccountID)
+	fmt.Println("Name:            ", a.Username)
+	fmt.Println("Status:          ", a.Status)
+	fmt.Println("Volumes:         ", a.Volumes)
+	if showSecrets {
+		fmt.Println("InitiatorSecret: ", string(a.InitiatorSecret))
+		fmt.Println("TargetSecret:    ", string(a.TargetSecret))
+	}
+	if a.Attributes != nil {
+		fmt.Println("Attributes:      ", a.Attributes)
+	}
+	fmt.Println("-------------------------------------------")
+}
+
+func printAccountList(accounts []sfapi.Account) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	defer tabWriter.Flush()
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", "ID", "NAME", "STATUS", "VOLUMES")
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", "==", "====", "======", "=======")
+	for _, a := range accounts {
+		fmt.Fprintf(tabWriter, "%d\t%s\t%s\t%d\n", a.AccountID, a.Username, a.Status, len(a.Volumes))
+	}
+	tabWriter.Flush()
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Total account count: ", len(accounts))
+	fmt.Println("-------------------------------------------")
+}
+
+func cmdAccountCreate(c *cli.Context) {
+	ctx := context.Background()
+	name := c.Args().First()
+	if name == "" {
+		fmt.Println("Missing argument to account create, requires NAME")
+		return
+	}
+	attrs, err := parseAttributes(c.String("attributes"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	req := sfapi.AddAccountRequest{
+		Username:        name,
+		InitiatorSecret: sfapi.Secret(c.String("initiator-secret")),
+		TargetSecret:    sfapi.Secret(c.String("target-secret")),
+	}
+	if attrs != nil {
+		req.Attributes = attrs
+	}
+	id, err := client.AddAccount(ctx, &req)
+	if err != nil {
+		fmt.Println("Error creating account: ", err)
+		return
+	}
+	a, err := client.GetAccountByID(ctx, &sfapi.GetAccountByIDRequest{AccountID: id})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("Succesfully Created Account:")
+	printAccount(a, false)
+}
+
+func cmdAccountList(c *cli.Context) {
+	accounts, err := client.ListAccounts(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printAccountList(accounts)
+}
+
+func cmdAccountShow(c *cli.Context) {
+	ctx := context.Background()
+	id, err := resolveAccountID(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	a, err := client.GetAccountByID(ctx, &sfapi.GetAccountByIDRequest{AccountID: id})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printAccount(a, c.Bool("show-secrets"))
+	if len(a.Volumes) == 0 {
+		return
+	}
+	e, err := client.GetAccountEfficiency(ctx, id)
+	if err != nil {
+		fmt.Println("Unable to get account efficiency: ", err)
+		return
+	}
+	fmt.Printf("Compression:      %.2fx\n", e.Compression)
+	fmt.Printf("Deduplication:    %.2fx\n", e.Deduplication)
+	fmt.Printf("ThinProvisioning: %.2fx\n", e.ThinProvisioning)
+	if len(e.MissingVolumes) > 0 {
+		fmt.Println("MissingVolumes:  ", e.MissingVolumes)
+	}
+	fmt.Println("-------------------------------------------")
+}
+
+func cmdAccountModify(c *cli.Context) {
+	ctx := context.Background()
+	id, err := resolveAccountID(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	attrs, err := parseAttributes(c.String("attributes"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	req := sfapi.ModifyAccountRequest{
+		AccountID:       id,
+		Status:          c.String("status"),
+		InitiatorSecret: sfapi.Secret(c.String("initiator-secret")),
+		TargetSecret:    sfapi.Secret(c.String("target-secret")),
+	}
+	if attrs != nil {
+		req.Attributes = attrs
+	}
+	a, err := client.ModifyAccount(ctx, &req)
+	if err != nil {
+		fmt.Println("Error modifying account: ", err)
+		return
+	}
+	fmt.Println("Succesfully Modified Account:")
+	printAccount(a, false)
+}
+
+func cmdAccountDelete(c *cli.Context) {
+	ctx := context.Background()
+	id, err := resolveAccountID(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := client.RemoveAccount(ctx, id); err != nil {
+		fmt.Println("Error deleting account: ", err)
+		return
+	}
+	fmt.Printf("Succesfully deleted account ID: %d\n", id)
+}
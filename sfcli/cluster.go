@@ -0,0 +1,328 @@
+package sfcli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/codegangsta/cli"
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+var (
+	clusterCmd = cli.Command{
+		Name:   "cluster",
+		Usage:  "cluster related commands",
+		Before: negotiateAPIVersion,
+		Subcommands: []cli.Command{
+			clusterInfoCmd,
+			clusterCapacityCmd,
+			clusterFaultsCmd,
+			clusterEventsCmd,
+		},
+	}
+
+	clusterFaultsCmd = cli.Command{
+		Name:  "faults",
+		Usage: "list the cluster's faults: `faults [options]`",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all",
+				Usage: "include resolved faults: `[--all]`",
+			},
+			cli.StringFlag{
+				Name:  "since",
+				Usage: "only faults raised within this long, or since this RFC3339 time: `[--since 24h]`",
+			},
+			cli.StringFlag{
+				Name:  "volume",
+				Usage: "only faults affecting this volume ID or name, or not about any volume: `[--volume <VOL>]`",
+			},
+			cli.StringFlag{
+				Name:  "severity",
+				Usage: "minimum severity to list: `[--severity bestPractice|warning|error|critical]`",
+			},
+		},
+		Action: cmdClusterFaults,
+	}
+
+	clusterEventsCmd = cli.Command{
+		Name:  "events",
+		Usage: "list the cluster's event log: `events [options]`",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "since",
+				Usage: "only events reported within this long, or since this RFC3339 time: `[--since 1h]`",
+			},
+			cli.StringFlag{
+				Name:  "volume",
+				Usage: "only events about this volume ID or name: `[--volume <VOL>]`",
+			},
+			cli.IntFlag{
+				Name:  "severity",
+				Usage: "minimum severity to list: `[--severity 2]`",
+			},
+			cli.IntFlag{
+				Name:  "limit, l",
+				Value: 100,
+				Usage: "maximum number of events to retrieve per request: `[--limit 100]`",
+			},
+			cli.BoolFlag{
+				Name:  "follow, f",
+				Usage: "keep polling for and printing new events until interrupted: `[--follow]`",
+			},
+			cli.DurationFlag{
+				Name:  "interval",
+				Value: 5 * time.Second,
+				Usage: "how often to poll for new events with --follow: `[--interval 5s]`",
+			},
+		},
+		Action: cmdClusterEvents,
+	}
+
+	clusterInfoCmd = cli.Command{
+		Name:   "info",
+		Usage:  "show the cluster's name, addresses and version: `info`",
+		Action: cmdClusterInfo,
+	}
+
+	clusterCapacityCmd = cli.Command{
+		Name:   "capacity",
+		Usage:  "show the cluster's used and provisioned space, efficiency and fullness: `capacity`",
+		Action: cmdClusterCapacity,
+	}
+)
+
+// formatBytes formats n in GiB, or TiB once it's large enough
+func formatBytes(n int64) string {
+	if n >= int64(units.TiB) {
+		return fmt.Sprintf("%.2f TiB", float64(n)/float64(units.TiB))
+	}
+	return fmt.Sprintf("%.2f GiB", float64(n)/float64(units.GiB))
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+func cmdClusterInfo(c *cli.Context) {
+	ctx := context.Background()
+	info, err := client.GetClusterInfo(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	version, err := client.GetClusterVersionInfo(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Name:         ", info.Name)
+	fmt.Println("UUID:         ", info.UUID)
+	fmt.Println("MVIP:         ", info.MVIP)
+	fmt.Println("SVIP:         ", info.SVIP)
+	fmt.Println("Ensemble:     ", strings.Join(info.Ensemble, ", "))
+	fmt.Println("Replicas:     ", info.RepCount)
+	fmt.Println("Version:      ", version.Result.ClusterVersion)
+	fmt.Println("API Version:  ", version.Result.ClusterAPIVersion, "(using", client.APIVersion()+")")
+	fmt.Println("Nodes:        ", len(version.Result.ClusterVersionInfo))
+	fmt.Println("-------------------------------------------")
+}
+
+func cmdClusterCapacity(c *cli.Context) {
+	ctx := context.Background()
+	capacity, err := client.GetClusterCapacity(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	threshold, err := client.GetClusterFullThreshold(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("-------------------------------------------")
+	fmt.Printf("Used:              %s of %s (%.1f%%)\n", formatBytes(capacity.UsedSpace),
+		formatBytes(capacity.MaxUsedSpace), percent(capacity.UsedSpace, capacity.MaxUsedSpace))
+	fmt.Printf("Provisioned:       %s of %s (%.1f%%)\n", formatBytes(capacity.ProvisionedSpace),
+		formatBytes(capacity.MaxProvisionedSpace), percent(capacity.ProvisionedSpace, capacity.MaxProvisionedSpace))
+	if capacity.MaxUsedMetadataSpace > 0 {
+		fmt.Printf("Metadata used:     %s of %s (%.1f%%)\n", formatBytes(capacity.UsedMetadataSpace),
+			formatBytes(capacity.MaxUsedMetadataSpace), percent(capacity.UsedMetadataSpace, capacity.MaxUsedMetadataSpace))
+	}
+	fmt.Println("-------------------------------------------")
+	fmt.Printf("Compression:       %.2fx\n", capacity.Compression())
+	fmt.Printf("Deduplication:     %.2fx\n", capacity.Deduplication())
+	fmt.Printf("Thin provisioning: %.2fx\n", capacity.ThinProvisioning())
+	fmt.Printf("Total efficiency:  %.2fx\n", capacity.Compression()*capacity.Deduplication()*capacity.ThinProvisioning())
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Block fullness:   ", threshold.BlockFullness)
+	fmt.Println("Metadata fullness:", threshold.MetadataFullness)
+	if threshold.Stage4BlockThresholdBytes > 0 {
+		fmt.Printf("Critical at:       %s used (%s of %s used now)\n", formatBytes(threshold.Stage4BlockThresholdBytes),
+			formatBytes(threshold.SumUsedClusterBytes), formatBytes(threshold.SumTotalClusterBytes))
+	}
+	fmt.Println("-------------------------------------------")
+}
+
+// parseSince parses a --since value, either a duration before now or an
+// RFC3339 time
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q, expected a duration such as 1h or an RFC3339 time", s)
+	}
+	return t, nil
+}
+
+func printFaultList(faults []sfapi.ClusterFault) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	defer tabWriter.Flush()
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "ID", "DATE", "SEVERITY", "TYPE", "CODE",
+		"RESOLVED", "DETAILS")
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "==", "====", "========", "====", "====",
+		"========", "=======")
+	for _, f := range faults {
+		fmt.Fprintf(tabWriter, "%d\t%s\t%s\t%s\t%s\t%t\t%s\n", f.ClusterFaultID, f.Date, f.Severity, f.Type,
+			f.Code, f.Resolved, f.Details)
+	}
+	tabWriter.Flush()
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Total fault count: ", len(faults))
+	fmt.Println("-------------------------------------------")
+}
+
+func printEvents(events []sfapi.Event, header bool) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	defer tabWriter.Flush()
+	if header {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\n", "ID", "TIME", "SEVERITY", "TYPE", "MESSAGE")
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\n", "==", "====", "========", "====", "=======")
+	}
+	for _, e := range events {
+		fmt.Fprintf(tabWriter, "%d\t%s\t%d\t%s\t%s\n", e.EventID, e.TimeOfReport, e.Severity, e.EventInfoType,
+			e.Message)
+	}
+}
+
+func cmdClusterFaults(c *cli.Context) {
+	ctx := context.Background()
+	var filter sfapi.FaultFilter
+	var err error
+	if filter.Since, err = parseSince(c.String("since")); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if c.String("volume") != "" {
+		if filter.VolumeID, err = resolveVolumeID(ctx, c.String("volume")); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if s := c.String("severity"); s != "" {
+		if sfapi.FaultSeverityLevel(s) < 0 {
+			fmt.Printf("Invalid --severity %q, expected one of %s\n", s, strings.Join(sfapi.FaultSeverities, ", "))
+			return
+		}
+		filter.MinSeverity = s
+	}
+	faultTypes := "current"
+	if c.Bool("all") {
+		faultTypes = "all"
+	}
+	faults, err := client.ListClusterFaults(ctx, faultTypes, filter)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printFaultList(faults)
+}
+
+func cmdClusterEvents(c *cli.Context) {
+	ctx := context.Background()
+	var filter sfapi.EventFilter
+	var err error
+	if filter.Since, err = parseSince(c.String("since")); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if c.String("volume") != "" {
+		if filter.VolumeID, err = resolveVolumeID(ctx, c.String("volume")); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	filter.MinSeverity = c.Int("severity")
+	req := sfapi.ListEventsRequest{MaxEvents: int64(c.Int("limit"))}
+
+	// Filtering is done on our side, so ask for everything and track the
+	// last event seen to know where to resume when following
+	unfiltered := sfapi.EventFilter{}
+	events, err := client.ListEvents(ctx, &req, unfiltered)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var lastID int64
+	var matched []sfapi.Event
+	for _, e := range events {
+		if e.EventID > lastID {
+			lastID = e.EventID
+		}
+		if filter.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	printEvents(matched, true)
+	if !c.Bool("follow") {
+		fmt.Println("-------------------------------------------")
+		fmt.Println("Total event count: ", len(matched))
+		fmt.Println("-------------------------------------------")
+		return
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(c.Duration("interval"))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+		// More than --limit events may have arrived since the last poll,
+		// only move past them once all of them have been fetched
+		events, err := client.ListEventsAfter(ctx, lastID, req.MaxEvents)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error polling events: ", err)
+			continue
+		}
+		matched = matched[:0]
+		for _, e := range events {
+			lastID = e.EventID
+			if filter.Match(e) {
+				matched = append(matched, e)
+			}
+		}
+		printEvents(matched, false)
+	}
+}
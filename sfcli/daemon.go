@@ -1,6 +1,7 @@
 package sfcli
 
 import (
+	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
 	"github.com/solidfire/solidfire-docker-driver/daemon"
 )
@@ -31,11 +32,17 @@ var (
 	}
 )
 
-func cmdDaemonStart(c *cli.Context) {
+func cmdDaemonStart(c *cli.Context) error {
 	verbose := c.Bool("verbose")
 	cfg := c.String("config")
 	if cfg == "" {
 		cfg = "/var/lib/solidfire/solidfire.json"
 	}
-	daemon.Start(cfg, verbose)
+	if err := daemon.Start(cfg, verbose); err != nil {
+		// The cli exits with status 1 (via os.Exit, so nothing deferred
+		// runs), the error is logged here as the exit message is empty
+		log.Error("SolidFire Docker daemon failed: ", err)
+		return cli.NewExitError("", 1)
+	}
+	return nil
 }
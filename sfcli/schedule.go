@@ -0,0 +1,325 @@
+package sfcli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/solidfire/solidfire-docker-driver/sfapi"
+)
+
+var (
+	scheduleCmd = cli.Command{
+		Name:   "schedule",
+		Usage:  "snapshot schedule related commands",
+		Before: negotiateAPIVersion,
+		Subcommands: []cli.Command{
+			scheduleCreateCmd,
+			scheduleListCmd,
+			scheduleShowCmd,
+			scheduleModifyCmd,
+		},
+	}
+
+	scheduleFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "frequency",
+			Usage: "how often to take snapshots: `[--frequency hourly|daily|weekly]`",
+		},
+		cli.IntFlag{
+			Name:  "every",
+			Value: 1,
+			Usage: "for hourly schedules, the number of hours between snapshots: `[--every 4]`",
+		},
+		cli.StringFlag{
+			Name:  "at",
+			Value: "00:00",
+			Usage: "for daily and weekly schedules, the time of day (cluster time) to take the snapshot: `[--at 02:30]`",
+		},
+		cli.StringFlag{
+			Name:  "day",
+			Value: "Sunday",
+			Usage: "for weekly schedules, the day of the week to take the snapshot: `[--day Saturday]`",
+		},
+		cli.StringFlag{
+			Name:  "retention",
+			Usage: "how long to keep each snapshot, default is until deleted: `[--retention 72h]`",
+		},
+		cli.StringSliceFlag{
+			Name:  "volume",
+			Usage: "Volume ID(s) or name(s) to snapshot: `[--volume <VOL-1> --volume <VOL-2>...]`",
+		},
+	}
+
+	scheduleCreateCmd = cli.Command{
+		Name:  "create",
+		Usage: "create a new snapshot schedule: `create --frequency hourly|daily|weekly [options] NAME`",
+		Flags: append([]cli.Flag{
+			cli.BoolFlag{
+				Name:  "paused",
+				Usage: "create the schedule paused: `[--paused]`",
+			},
+		}, scheduleFlags...),
+		Action: cmdScheduleCreate,
+	}
+
+	scheduleListCmd = cli.Command{
+		Name:   "list",
+		Usage:  "List snapshot schedules: `list`",
+		Action: cmdScheduleList,
+	}
+
+	scheduleShowCmd = cli.Command{
+		Name:   "show",
+		Usage:  "Show the details of a snapshot schedule: `show SCHEDULE`",
+		Action: cmdScheduleShow,
+	}
+
+	scheduleModifyCmd = cli.Command{
+		Name:  "modify",
+		Usage: "modify an existing snapshot schedule, --volume adds volumes to it: `modify [options] SCHEDULE`",
+		Flags: append([]cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "remove-volume",
+				Usage: "Volume ID(s) or name(s) to stop snapshotting: `[--remove-volume <VOL-1>...]`",
+			},
+			cli.BoolFlag{
+				Name:  "pause",
+				Usage: "pause the schedule: `[--pause]`",
+			},
+			cli.BoolFlag{
+				Name:  "resume",
+				Usage: "resume a paused schedule: `[--resume]`",
+			},
+		}, scheduleFlags...),
+		Action: cmdScheduleModify,
+	}
+)
+
+// parseTimeOfDay parses an HH:MM time
+func parseTimeOfDay(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) || strings.EqualFold(s, d.String()[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day %q, expected a day of the week", s)
+}
+
+// setFrequency applies the --frequency, --every, --at and --day flags to s
+func setFrequency(c *cli.Context, s *sfapi.Schedule) error {
+	switch c.String("frequency") {
+	case "hourly":
+		if c.Int("every") < 1 {
+			return fmt.Errorf("invalid --every %d, must be at least 1", c.Int("every"))
+		}
+		s.SetHourly(c.Int("every"))
+	case "daily":
+		hour, minute, err := parseTimeOfDay(c.String("at"))
+		if err != nil {
+			return err
+		}
+		s.SetDaily(hour, minute)
+	case "weekly":
+		hour, minute, err := parseTimeOfDay(c.String("at"))
+		if err != nil {
+			return err
+		}
+		day, err := parseWeekday(c.String("day"))
+		if err != nil {
+			return err
+		}
+		s.SetWeekly(day, hour, minute)
+	default:
+		return fmt.Errorf("invalid --frequency %q, expected hourly, daily or weekly", c.String("frequency"))
+	}
+	return nil
+}
+
+func parseRetention(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return "", fmt.Errorf("invalid retention %q, expected a duration such as 72h", s)
+	}
+	return sfapi.FormatRetention(d), nil
+}
+
+// resolveSchedule returns the schedule given by ID or by name on the
+// command line
+func resolveSchedule(ctx context.Context, arg string) (sfapi.Schedule, error) {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return client.GetSchedule(ctx, id)
+	}
+	return client.GetScheduleByName(ctx, arg)
+}
+
+func printSchedule(s sfapi.Schedule) {
+	retention := s.ScheduleInfo.Retention
+	if retention == "" {
+		retention = "until deleted"
+	}
+	fmt.Println("-------------------------------------------")
+	fmt.Println("ID:         ", s.ScheduleID)
+	fmt.Println("Name:       ", s.Name)
+	fmt.Println("Frequency:  ", s.Frequency())
+	fmt.Println("Retention:  ", retention)
+	fmt.Println("Volumes:    ", s.ScheduleInfo.VolumeIDs)
+	fmt.Println("Paused:     ", s.Paused)
+	fmt.Println("Last run:   ", s.LastRunStatus, s.LastRunTimeStarted)
+	fmt.Println("-------------------------------------------")
+}
+
+func printScheduleList(schedules []sfapi.Schedule) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	defer tabWriter.Flush()
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "ID", "NAME", "FREQUENCY", "RETENTION",
+		"VOLUMEIDS", "PAUSED", "LAST-RUN")
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "==", "====", "=========", "=========",
+		"=========", "======", "========")
+	count := 0
+	for _, s := range schedules {
+		if s.ToBeDeleted {
+			continue
+		}
+		var vols []string
+		for _, id := range s.ScheduleInfo.VolumeIDs {
+			vols = append(vols, strconv.FormatInt(id, 10))
+		}
+		fmt.Fprintf(tabWriter, "%d\t%s\t%s\t%s\t%s\t%t\t%s\n", s.ScheduleID, s.Name, s.Frequency(),
+			s.ScheduleInfo.Retention, strings.Join(vols, ","), s.Paused, s.LastRunStatus)
+		count++
+	}
+	tabWriter.Flush()
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Total schedule count: ", count)
+	fmt.Println("-------------------------------------------")
+}
+
+func cmdScheduleCreate(c *cli.Context) {
+	ctx := context.Background()
+	name := c.Args().First()
+	if name == "" {
+		fmt.Println("Missing argument to schedule create, requires NAME")
+		return
+	}
+	vols, err := resolveVolumeIDs(ctx, c.StringSlice("volume"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	s := sfapi.NewSnapshotSchedule(name, vols, 0)
+	if s.ScheduleInfo.Retention, err = parseRetention(c.String("retention")); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := setFrequency(c, s); err != nil {
+		fmt.Println(err)
+		return
+	}
+	s.Paused = c.Bool("paused")
+	created, err := client.CreateSchedule(ctx, s)
+	if err != nil {
+		fmt.Println("Error creating schedule: ", err)
+		return
+	}
+	fmt.Println("Succesfully Created Schedule:")
+	printSchedule(created)
+}
+
+func cmdScheduleList(c *cli.Context) {
+	schedules, err := client.ListSchedules(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printScheduleList(schedules)
+}
+
+func cmdScheduleShow(c *cli.Context) {
+	s, err := resolveSchedule(context.Background(), c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printSchedule(s)
+}
+
+func cmdScheduleModify(c *cli.Context) {
+	ctx := context.Background()
+	s, err := resolveSchedule(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if c.String("frequency") != "" {
+		if err := setFrequency(c, &s); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if c.String("retention") != "" {
+		if s.ScheduleInfo.Retention, err = parseRetention(c.String("retention")); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	add, err := resolveVolumeIDs(ctx, c.StringSlice("volume"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	remove, err := resolveVolumeIDs(ctx, c.StringSlice("remove-volume"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var vols []int64
+	for _, id := range append(s.ScheduleInfo.VolumeIDs, add...) {
+		if !containsInt64(vols, id) && !containsInt64(remove, id) {
+			vols = append(vols, id)
+		}
+	}
+	s.ScheduleInfo.VolumeIDs = vols
+	if c.Bool("pause") && c.Bool("resume") {
+		fmt.Println("Only one of --pause and --resume may be given")
+		return
+	}
+	if c.Bool("pause") {
+		s.Paused = true
+	} else if c.Bool("resume") {
+		s.Paused = false
+	}
+	modified, err := client.ModifySchedule(ctx, &s)
+	if err != nil {
+		fmt.Println("Error modifying schedule: ", err)
+		return
+	}
+	fmt.Println("Succesfully Modified Schedule:")
+	printSchedule(modified)
+}
+
+func containsInt64(list []int64, v int64) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
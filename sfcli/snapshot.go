@@ -1,6 +1,7 @@
 package sfcli
 
 import (
+	"context"
 	"fmt"
 
 	"encoding/json"
@@ -13,16 +14,77 @@ import (
 
 var (
 	snapshotCmd = cli.Command{
-		Name:  "snapshot",
-		Usage: "snapshot related commands",
+		Name:   "snapshot",
+		Usage:  "snapshot related commands",
+		Before: negotiateAPIVersion,
 		Subcommands: []cli.Command{
 			snapshotCreateCmd,
 			snapshotDeleteCmd,
 			snapshotListCmd,
 			snapshotRollbackCmd,
+			snapshotGroupCmd,
 		},
 	}
 
+	snapshotGroupCmd = cli.Command{
+		Name:  "group",
+		Usage: "group snapshot (consistent multi-volume snapshot) commands",
+		Subcommands: []cli.Command{
+			snapshotGroupCreateCmd,
+			snapshotGroupListCmd,
+			snapshotGroupDeleteCmd,
+			snapshotGroupRollbackCmd,
+		},
+	}
+
+	snapshotGroupCreateCmd = cli.Command{
+		Name:  "create",
+		Usage: "snapshot several volumes at the same point in time: `create [options] VOLUME [VOLUME...]`",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "name",
+				Usage: "Name to assign to group snapshot, default is a time/date stamp: `[--name <GROUP_NAME>]`",
+			},
+		},
+		Action: cmdSnapshotGroupCreate,
+	}
+
+	snapshotGroupListCmd = cli.Command{
+		Name:  "list",
+		Usage: "List existing group snapshots: `list`",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "volume",
+				Usage: "Retrieve group snapshots only for the specified volume ID or name: `[--volume VOLUME]`",
+			},
+		},
+		Action: cmdSnapshotGroupList,
+	}
+
+	snapshotGroupDeleteCmd = cli.Command{
+		Name:  "delete",
+		Usage: "Delete existing group snapshots: `delete [options] GROUP [GROUP...]`",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "save-members",
+				Usage: "Keep the member snapshots as individual snapshots: `[--save-members]`",
+			},
+		},
+		Action: cmdSnapshotGroupDelete,
+	}
+
+	snapshotGroupRollbackCmd = cli.Command{
+		Name:  "rollback",
+		Usage: "Rollback every volume in a group snapshot: `rollback [options] GROUP`",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "save",
+				Usage: "Save the current state of the volumes as a new group snapshot with this name first: `[--save <GROUP_NAME>]`",
+			},
+		},
+		Action: cmdSnapshotGroupRollback,
+	}
+
 	snapshotCreateCmd = cli.Command{
 		Name:  "create",
 		Usage: "create a new snapshot: `create [options] SRC_VOLID`",
@@ -69,7 +131,7 @@ func cmdSnapshotCreate(c *cli.Context) {
 	if c.String("name") != "" {
 		req.Name = c.String("name")
 	}
-	response, err := client.Request("CreateSnapshot", req, sfapi.NewReqID())
+	response, err := client.Request(context.Background(), "CreateSnapshot", req, sfapi.NewReqID())
 	if err != nil {
 		log.Errorf("Create snapshot failed: ", err)
 		return
@@ -77,7 +139,7 @@ func cmdSnapshotCreate(c *cli.Context) {
 	if err := json.Unmarshal([]byte(response), &result); err != nil {
 		log.Fatal(err)
 	}
-	s, err := client.GetSnapshot(result.Result.SnapshotID, "")
+	s, err := client.GetSnapshot(context.Background(), result.Result.SnapshotID, "")
 
 	fmt.Println("-------------------------------------------")
 	fmt.Println("Succesfully Created Snapshot:")
@@ -91,7 +153,7 @@ func cmdSnapshotCreate(c *cli.Context) {
 func cmdSnapshotDelete(c *cli.Context) {
 	for _, arg := range c.Args() {
 		sID, _ := strconv.ParseInt(arg, 10, 64)
-		client.DeleteSnapshot(sID)
+		client.DeleteSnapshot(context.Background(), sID)
 	}
 }
 
@@ -105,14 +167,14 @@ func cmdSnapshotRollback(c *cli.Context) {
 	var req sfapi.RollbackToSnapshotRequest
 	req.VolumeID = vID
 	req.SnapshotID = sID
-	client.RollbackToSnapshot(&req)
+	client.RollbackToSnapshot(context.Background(), &req)
 }
 
 func cmdSnapshotList(c *cli.Context) {
 	volID, _ := strconv.ParseInt(c.String("volume"), 10, 64)
 	var req sfapi.ListSnapshotsRequest
 	req.VolumeID = volID
-	snapshots, err := client.ListSnapshots(&req)
+	snapshots, err := client.ListSnapshots(context.Background(), &req)
 
 	if err != nil {
 		fmt.Println(err)
@@ -120,3 +182,105 @@ func cmdSnapshotList(c *cli.Context) {
 		printSnapList(snapshots)
 	}
 }
+
+// resolveGroupSnapshot returns the group snapshot given by ID or by name on
+// the command line
+func resolveGroupSnapshot(ctx context.Context, arg string) (sfapi.GroupSnapshot, error) {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return client.GetGroupSnapshotByID(ctx, id)
+	}
+	return client.GetGroupSnapshotByName(ctx, arg)
+}
+
+func cmdSnapshotGroupCreate(c *cli.Context) {
+	ctx := context.Background()
+	if len(c.Args()) < 1 {
+		fmt.Println("Missing argument to group create, requires at least one volume ID or name")
+		return
+	}
+	var req sfapi.CreateGroupSnapshotRequest
+	vols, err := resolveVolumeIDs(ctx, c.Args())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	req.Volumes = vols
+	req.Name = c.String("name")
+	g, err := client.CreateGroupSnapshot(ctx, &req)
+	if err != nil {
+		fmt.Println("Create group snapshot failed: ", err)
+		return
+	}
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Succesfully Created Group Snapshot:")
+	fmt.Println("-------------------------------------------")
+	fmt.Println("ID:         ", g.GroupSnapshotID)
+	fmt.Println("Name:       ", g.Name)
+	for _, m := range g.Members {
+		fmt.Println("Member:     ", "VolumeID:", m.VolumeID, "SnapshotID:", m.SnapshotID)
+	}
+	fmt.Println("-------------------------------------------")
+}
+
+func cmdSnapshotGroupList(c *cli.Context) {
+	ctx := context.Background()
+	var req sfapi.ListGroupSnapshotsRequest
+	if c.String("volume") != "" {
+		volID, err := resolveVolumeID(ctx, c.String("volume"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		req.Volumes = []int64{volID}
+	}
+	groups, err := client.ListGroupSnapshots(ctx, &req)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		printGroupSnapList(groups)
+	}
+}
+
+func cmdSnapshotGroupDelete(c *cli.Context) {
+	ctx := context.Background()
+	for _, arg := range c.Args() {
+		g, err := resolveGroupSnapshot(ctx, arg)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if err := client.DeleteGroupSnapshot(ctx, g.GroupSnapshotID, c.Bool("save-members")); err != nil {
+			fmt.Printf("Failed to delete group snapshot %d: %v\n", g.GroupSnapshotID, err)
+			continue
+		}
+		fmt.Printf("Succesfully deleted group snapshot ID: %d\n", g.GroupSnapshotID)
+	}
+}
+
+func cmdSnapshotGroupRollback(c *cli.Context) {
+	ctx := context.Background()
+	if len(c.Args()) < 1 {
+		fmt.Println("Missing argument to group rollback, requires <group snapshot ID or name>")
+		return
+	}
+	g, err := resolveGroupSnapshot(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var req sfapi.RollbackToGroupSnapshotRequest
+	req.GroupSnapshotID = g.GroupSnapshotID
+	if c.String("save") != "" {
+		req.SaveCurrentState = true
+		req.Name = c.String("save")
+	}
+	saved, err := client.RollbackToGroupSnapshot(ctx, &req)
+	if err != nil {
+		fmt.Println("Rollback to group snapshot failed: ", err)
+		return
+	}
+	fmt.Printf("Succesfully rolled back %d volumes to group snapshot ID: %d\n", len(g.Members), g.GroupSnapshotID)
+	if req.SaveCurrentState {
+		fmt.Printf("Previous state saved as group snapshot ID: %d\n", saved.GroupSnapshotID)
+	}
+}
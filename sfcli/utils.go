@@ -1,6 +1,7 @@
 package sfcli
 
 import (
+	"context"
 	"fmt"
 	"github.com/alecthomas/units"
 	"github.com/solidfire/solidfire-docker-driver/sfapi"
@@ -12,6 +13,19 @@ import (
 	"unicode/utf8"
 )
 
+// parseSize parses a --size value given either as a plain number of bytes
+// or with units, ie 1073741824 or 1GiB
+func parseSize(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		n, err = units.ParseStrictBytes(s)
+	}
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q, expected bytes or a size such as 10GiB", s)
+	}
+	return n, nil
+}
+
 func printStruct(x interface{}) {
 	v := reflect.ValueOf(x)
 	maxL := 10
@@ -57,6 +71,22 @@ func printVolList(volumes []sfapi.Volume) {
 	fmt.Println("-------------------------------------------")
 }
 
+func printDeletedVolList(volumes []sfapi.Volume) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	defer tabWriter.Flush()
+	fmt.Fprintln(tabWriter, "ID\tName\tAccountID\tSize(GiB)\tDeletedAt\tPurgeAt")
+	fmt.Fprintf(tabWriter, "%s\n", "=================================================")
+	for _, v := range volumes {
+		fmt.Fprintf(tabWriter, "%d\t%s\t%d\t%d\t%s\t%s\n", v.VolumeID, v.Name,
+			v.AccountID, v.TotalSize/int64(units.GiB), v.DeleteTime, v.PurgeTime)
+	}
+	tabWriter.Flush()
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Total deleted volume count: ", len(volumes))
+	fmt.Println("-------------------------------------------")
+}
+
 func printSnapList(snapshots []sfapi.Snapshot) {
 	var provisioned int64
 	provisioned = 0
@@ -79,6 +109,128 @@ func printSnapList(snapshots []sfapi.Snapshot) {
 	fmt.Println("-------------------------------------------")
 }
 
+func printGroupSnapList(groups []sfapi.GroupSnapshot) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	defer tabWriter.Flush()
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\n", "ID", "NAME", "STATUS", "VOLUMEIDS", "CREATED-AT")
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\n", "==", "====", "======", "=========", "==========")
+	for _, g := range groups {
+		var vols []string
+		for _, m := range g.Members {
+			vols = append(vols, strconv.FormatInt(m.VolumeID, 10))
+		}
+		fmt.Fprintf(tabWriter, "%d\t%s\t%s\t%s\t%s\n", g.GroupSnapshotID, g.Name, g.Status,
+			strings.Join(vols, ","), g.CreateTime)
+	}
+	tabWriter.Flush()
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Total group snapshot count: ", len(groups))
+	fmt.Println("-------------------------------------------")
+}
+
+// resolveVolumeID returns the ID of the volume given by ID or by name on
+// the command line.  Names are only unique per account, so a name matching
+// more than one active volume is rejected.
+func resolveVolumeID(ctx context.Context, arg string) (int64, error) {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return id, nil
+	}
+	var found []sfapi.Volume
+	it := client.ListActiveVolumesIter(ctx, 0)
+	for it.Next() {
+		if v := it.Volume(); v.Name == arg {
+			found = append(found, v)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return 0, err
+	}
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("no volume named %s", arg)
+	case 1:
+		return found[0].VolumeID, nil
+	}
+	var ids []string
+	for _, v := range found {
+		ids = append(ids, fmt.Sprintf("%d (account %d)", v.VolumeID, v.AccountID))
+	}
+	return 0, fmt.Errorf("volume name %s is ambiguous, use one of the IDs: %s", arg, strings.Join(ids, ", "))
+}
+
+// resolveAccountID returns the ID of the account given by ID or by name on
+// the command line
+func resolveAccountID(ctx context.Context, arg string) (int64, error) {
+	if arg == "" {
+		return 0, fmt.Errorf("missing account, requires an account ID or name")
+	}
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return id, nil
+	}
+	a, err := client.GetAccountByName(ctx, &sfapi.GetAccountByNameRequest{Name: arg})
+	if err != nil {
+		if sfapi.IsNotFound(err) {
+			return 0, fmt.Errorf("no account named %s", arg)
+		}
+		return 0, err
+	}
+	return a.AccountID, nil
+}
+
+// resolveVolumeIDs is resolveVolumeID for a list of volumes
+func resolveVolumeIDs(ctx context.Context, args []string) ([]int64, error) {
+	var ids []int64
+	for _, arg := range args {
+		id, err := resolveVolumeID(ctx, arg)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// printProgress reports the status of a long running cluster operation on a
+// single, continually updated line
+func printProgress(r sfapi.AsyncResult) {
+	if pct, ok := r.PercentComplete(); ok {
+		fmt.Printf("\r%s %s: %d%% complete", r.ResultType, r.Status, pct)
+		return
+	}
+	fmt.Printf("\r%s %s (last update %s)", r.ResultType, r.Status, r.LastUpdateTime)
+}
+
+// parseQoS parses a `min,max,burst` IOPS setting
+func parseQoS(s string) (qos sfapi.QoS, err error) {
+	iops := strings.Split(s, ",")
+	if len(iops) != 3 {
+		return qos, fmt.Errorf("invalid qos %q, expected min,max,burst", s)
+	}
+	vals := make([]int64, 3)
+	for i, v := range iops {
+		vals[i], err = strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return qos, fmt.Errorf("invalid qos %q, expected min,max,burst: %v", s, err)
+		}
+	}
+	qos.MinIOPS, qos.MaxIOPS, qos.BurstIOPS = vals[0], vals[1], vals[2]
+	return qos, nil
+}
+
+// lookupVolType returns the volume type with the given name from the
+// config file
+func lookupVolType(name string) (sfapi.VolType, error) {
+	if client.Config.Types != nil {
+		for _, t := range *client.Config.Types {
+			if t.Type == name {
+				return t, nil
+			}
+		}
+	}
+	return sfapi.VolType{}, fmt.Errorf("volume type %s is not defined in the config file", name)
+}
+
 func confirm() bool {
 	var resp string
 	_, err := fmt.Scanln(&resp)
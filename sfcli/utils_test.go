@@ -0,0 +1,22 @@
+package sfcli
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"1073741824": 1 << 30,
+		"1GiB":       1 << 30,
+		"10GiB":      10 << 30,
+		"512MiB":     512 << 20,
+	} {
+		got, err := parseSize(s)
+		if err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, expected %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "big", "1Gb", "-", "-5", "0", "0GiB", "-1GiB"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("expected parseSize(%q) to fail", s)
+		}
+	}
+}
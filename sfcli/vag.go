@@ -1,21 +1,30 @@
 package sfcli
 
 import (
-
-	//"github.com/alecthomas/units"
+	"context"
 	"fmt"
 	"github.com/codegangsta/cli"
 	"github.com/solidfire/solidfire-docker-driver/sfapi"
+	"os"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 )
 
 var (
 	vagCmd = cli.Command{
-		Name:  "vag",
-		Usage: "VAG (Volume Access Group) related commands",
+		Name:   "vag",
+		Usage:  "VAG (Volume Access Group) related commands",
+		Before: negotiateAPIVersion,
 		Subcommands: []cli.Command{
 			vagCreateCmd,
 			vagListCmd,
+			vagShowCmd,
+			vagDeleteCmd,
+			vagAddInitiatorCmd,
+			vagRemoveInitiatorCmd,
+			vagAddVolumeCmd,
+			vagRemoveVolumeCmd,
 		},
 	}
 
@@ -29,7 +38,7 @@ var (
 			},
 			cli.StringSliceFlag{
 				Name:  "volume",
-				Usage: "Volume ID(s) to add to the newly create VAG: `[--volume <VOLID-1> --volume <VOLID-2>...]`",
+				Usage: "Volume ID(s) or name(s) to add to the newly create VAG: `[--volume <VOL-1> --volume <VOL-2>...]`",
 			},
 		},
 		Action: cmdVagCreate,
@@ -40,30 +49,227 @@ var (
 		Usage:  "List Volume Access Groups: `list`",
 		Action: cmdVagList,
 	}
+
+	vagShowCmd = cli.Command{
+		Name:   "show",
+		Usage:  "Show a Volume Access Group's initiators and volumes: `show VAG`",
+		Action: cmdVagShow,
+	}
+
+	vagDeleteCmd = cli.Command{
+		Name:   "delete",
+		Usage:  "Delete a Volume Access Group, its volumes are kept: `delete VAG`",
+		Action: cmdVagDelete,
+	}
+
+	vagAddInitiatorCmd = cli.Command{
+		Name:   "add-initiator",
+		Usage:  "Add initiators to a Volume Access Group: `add-initiator VAG IQN...`",
+		Action: cmdVagAddInitiator,
+	}
+
+	vagRemoveInitiatorCmd = cli.Command{
+		Name:   "remove-initiator",
+		Usage:  "Remove initiators from a Volume Access Group: `remove-initiator VAG IQN...`",
+		Action: cmdVagRemoveInitiator,
+	}
+
+	vagAddVolumeCmd = cli.Command{
+		Name:   "add-volume",
+		Usage:  "Add volumes to a Volume Access Group: `add-volume VAG VOLUME...`",
+		Action: cmdVagAddVolume,
+	}
+
+	vagRemoveVolumeCmd = cli.Command{
+		Name:   "remove-volume",
+		Usage:  "Remove volumes from a Volume Access Group: `remove-volume VAG VOLUME...`",
+		Action: cmdVagRemoveVolume,
+	}
 )
 
+// resolveVAG returns the VAG given by ID or by name on the command line
+func resolveVAG(ctx context.Context, arg string) (sfapi.VolumeAccessGroup, error) {
+	if arg == "" {
+		return sfapi.VolumeAccessGroup{}, fmt.Errorf("missing VAG, requires a VAG ID or name")
+	}
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return client.GetVolumeAccessGroupByID(ctx, id)
+	}
+	return client.GetVolumeAccessGroupByName(ctx, arg)
+}
+
+func printVagList(vags []sfapi.VolumeAccessGroup) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	defer tabWriter.Flush()
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", "ID", "NAME", "INITIATORS", "VOLUMES")
+	fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", "==", "====", "==========", "=======")
+	for _, g := range vags {
+		fmt.Fprintf(tabWriter, "%d\t%s\t%d\t%d\n", g.VAGID, g.Name, len(g.Initiators), len(g.Volumes))
+	}
+	tabWriter.Flush()
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Total VAG count: ", len(vags))
+	fmt.Println("-------------------------------------------")
+}
+
 func cmdVagList(c *cli.Context) {
 	var req sfapi.ListVolumeAccessGroupsRequest
-	groups, err := client.ListVolumeAccessGroups(&req)
+	groups, err := client.ListVolumeAccessGroups(context.Background(), &req)
 	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printVagList(groups)
+}
 
+func cmdVagShow(c *cli.Context) {
+	ctx := context.Background()
+	g, err := resolveVAG(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
-	fmt.Println(groups)
+	var volumes []sfapi.Volume
+	for _, id := range g.Volumes {
+		v, err := client.GetVolumeByID(ctx, id)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		volumes = append(volumes, v)
+	}
+	fmt.Println("-------------------------------------------")
+	fmt.Println("ID:          ", g.VAGID)
+	fmt.Println("Name:        ", g.Name)
+	fmt.Println("Initiators:  ", strings.Join(g.Initiators, ", "))
+	if len(g.DeletedVolumes) > 0 {
+		fmt.Println("Deleted volumes: ", g.DeletedVolumes)
+	}
+	fmt.Println("-------------------------------------------")
+	printVolList(volumes)
 }
 
 func cmdVagCreate(c *cli.Context) {
+	ctx := context.Background()
 	var req sfapi.CreateVolumeAccessGroupRequest
 	req.Name = c.Args().First()
+	if req.Name == "" {
+		fmt.Println("Missing argument to vag create, requires NAME")
+		return
+	}
 	for _, init := range c.StringSlice("initiator") {
 		req.Initiators = append(req.Initiators, init)
 	}
+	vols, err := resolveVolumeIDs(ctx, c.StringSlice("volume"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	req.Volumes = vols
 
-	for _, vol := range c.StringSlice("volume") {
-		id, _ := strconv.ParseInt(vol, 10, 64)
-		req.Volumes = append(req.Volumes, id)
+	vagID, err := client.CreateVolumeAccessGroup(ctx, &req)
+	if err != nil {
+		fmt.Println("Error creating VAG: ", err)
+		return
 	}
+	fmt.Printf("VAG ID is: %d\n", vagID)
+}
 
-	vagID, _ := client.CreateVolumeAccessGroup(&req)
-	fmt.Println("VAG ID is: %s", vagID)
+func cmdVagDelete(c *cli.Context) {
+	ctx := context.Background()
+	g, err := resolveVAG(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := client.DeleteVolumeAccessGroup(ctx, g.VAGID); err != nil {
+		fmt.Println("Error deleting VAG: ", err)
+		return
+	}
+	fmt.Printf("Succesfully deleted VAG ID: %d\n", g.VAGID)
+}
+
+func cmdVagAddInitiator(c *cli.Context) {
+	ctx := context.Background()
+	if len(c.Args()) < 2 {
+		fmt.Println("Missing arguments to vag add-initiator, requires VAG IQN...")
+		return
+	}
+	g, err := resolveVAG(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	req := sfapi.AddInitiatorsToVolumeAccessGroupRequest{VAGID: g.VAGID, Initiators: c.Args().Tail()}
+	if err := client.AddInitiatorsToVolumeAccessGroup(ctx, &req); err != nil {
+		fmt.Println("Error adding initiators to VAG: ", err)
+		return
+	}
+	fmt.Printf("Succesfully added initiators to VAG ID: %d\n", g.VAGID)
+}
+
+func cmdVagRemoveInitiator(c *cli.Context) {
+	ctx := context.Background()
+	if len(c.Args()) < 2 {
+		fmt.Println("Missing arguments to vag remove-initiator, requires VAG IQN...")
+		return
+	}
+	g, err := resolveVAG(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	req := sfapi.RemoveInitiatorsFromVolumeAccessGroupRequest{VAGID: g.VAGID, Initiators: c.Args().Tail()}
+	if err := client.RemoveInitiatorsFromVolumeAccessGroup(ctx, &req); err != nil {
+		fmt.Println("Error removing initiators from VAG: ", err)
+		return
+	}
+	fmt.Printf("Succesfully removed initiators from VAG ID: %d\n", g.VAGID)
+}
 
+func cmdVagAddVolume(c *cli.Context) {
+	ctx := context.Background()
+	if len(c.Args()) < 2 {
+		fmt.Println("Missing arguments to vag add-volume, requires VAG VOLUME...")
+		return
+	}
+	g, err := resolveVAG(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	vols, err := resolveVolumeIDs(ctx, c.Args().Tail())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := client.AddVolumeToAccessGroup(ctx, g.VAGID, vols); err != nil {
+		fmt.Println("Error adding volumes to VAG: ", err)
+		return
+	}
+	fmt.Printf("Succesfully added volumes to VAG ID: %d\n", g.VAGID)
+}
+
+func cmdVagRemoveVolume(c *cli.Context) {
+	ctx := context.Background()
+	if len(c.Args()) < 2 {
+		fmt.Println("Missing arguments to vag remove-volume, requires VAG VOLUME...")
+		return
+	}
+	g, err := resolveVAG(ctx, c.Args().First())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	vols, err := resolveVolumeIDs(ctx, c.Args().Tail())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := client.RemoveVolumesFromVolumeAccessGroup(ctx, g.VAGID, vols); err != nil {
+		fmt.Println("Error removing volumes from VAG: ", err)
+		return
+	}
+	fmt.Printf("Succesfully removed volumes from VAG ID: %d\n", g.VAGID)
 }
@@ -1,6 +1,7 @@
 package sfcli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -13,17 +14,62 @@ import (
 
 var (
 	volumeCmd = cli.Command{
-		Name:  "volume",
-		Usage: "volume related commands",
+		Name:   "volume",
+		Usage:  "volume related commands",
+		Before: negotiateAPIVersion,
 		Subcommands: []cli.Command{
 			volumeCreateCmd,
 			volumeCloneCmd,
+			volumeModifyCmd,
 			volumeDeleteCmd,
 			volumeListCmd,
 			volumeAttachCmd,
 			volumeDetachCmd,
 			volumeAddToVag,
 			volumeRollbackCmd,
+			volumeDeletedCmd,
+		},
+	}
+
+	volumeDeletedCmd = cli.Command{
+		Name:  "deleted",
+		Usage: "list, restore or purge deleted volumes that the cluster hasn't purged yet",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "list deleted volumes: `list [--account ID]`",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "account, a",
+						Usage: ": only list deleted volumes for the specified account ID or name `[--account <account>]`",
+					},
+				},
+				Action: cmdVolumeDeletedList,
+			},
+			{
+				Name:   "restore",
+				Usage:  "restore deleted volumes: `restore VOLUME-ID...`",
+				Action: cmdVolumeDeletedRestore,
+			},
+			{
+				Name:  "purge",
+				Usage: "permanently remove deleted volumes: `purge [--all|--account ID] [VOLUME-ID...]`",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "all",
+						Usage: "purge every deleted volume: `[--all]`",
+					},
+					cli.StringFlag{
+						Name:  "account, a",
+						Usage: ": purge every deleted volume for the specified account ID or name `[--account <account>]`",
+					},
+					cli.BoolFlag{
+						Name:  "force, f",
+						Usage: "don't ask for confirmation: `[--force]`",
+					},
+				},
+				Action: cmdVolumeDeletedPurge,
+			},
 		},
 	}
 
@@ -37,7 +83,7 @@ var (
 			},
 			cli.StringFlag{
 				Name:  "account",
-				Usage: "account id to assign volume: `[--account 488]`",
+				Usage: "account id or name to assign volume: `[--account 488|docker]`",
 			},
 			cli.StringFlag{
 				Name:  "qos",
@@ -61,6 +107,30 @@ var (
 		Action: cmdVolumeClone,
 	}
 
+	volumeModifyCmd = cli.Command{
+		Name:  "modify",
+		Usage: "modify an existing volume: `modify [options] VOLUME-ID`",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "size",
+				Usage: "new size of volume in bytes or GiB, volumes can only grow: `[--size 1073741824|1GiB]`",
+			},
+			cli.StringFlag{
+				Name:  "qos",
+				Usage: "min,max and burst qos settings for the volume: `[--qos 1000,5000,15000]`",
+			},
+			cli.StringFlag{
+				Name:  "type",
+				Usage: "Specify a volume type as defined in a SolidFire config file: `[--type Gold]`",
+			},
+			cli.StringFlag{
+				Name:  "access",
+				Usage: "access mode for the volume: `[--access readWrite|readOnly|locked|replicationTarget]`",
+			},
+		},
+		Action: cmdVolumeModify,
+	}
+
 	volumeRollbackCmd = cli.Command{
 		Name:   "rollback",
 		Usage:  "rollback a volume to a previously taken snapshot `rollback [options] VOLUME_ID SNAPSHOT_ID`",
@@ -97,7 +167,7 @@ var (
 			cli.StringFlag{
 				Name:  "account, a",
 				Value: "",
-				Usage: ": only retrieve volumes for the specified accountID `[--account <accountID>]` (not compatible with other options)",
+				Usage: ": only retrieve volumes for the specified account ID or name `[--account <account>]` (not compatible with other options)",
 			},
 		},
 		Action: cmdVolumeList,
@@ -131,7 +201,7 @@ var (
 func cmdVolumeAttach(c *cli.Context) {
 	id := c.Args().First()
 	volID, _ := strconv.ParseInt(id, 10, 64)
-	v, err := client.GetVolumeByID(volID)
+	v, err := client.GetVolumeByID(context.Background(), volID)
 	if err != nil {
 		err = errors.New("Failed to find volume for attach")
 		return
@@ -140,7 +210,7 @@ func cmdVolumeAttach(c *cli.Context) {
 	if c.String("iface") == "" {
 		netDev = "default"
 	}
-	path, device, err := client.AttachVolume(&v, netDev)
+	path, device, err := client.AttachVolume(context.Background(), &v, netDev)
 	if err != nil {
 		fmt.Println("Error encountered while performing iSCSI attach on Volume: ", volID)
 		fmt.Println(err)
@@ -161,8 +231,8 @@ func cmdVolumeAttach(c *cli.Context) {
 func cmdVolumeDetach(c *cli.Context) {
 	id := c.Args().First()
 	volID, _ := strconv.ParseInt(id, 10, 64)
-	v, err := client.GetVolumeByID(volID)
-	err = client.DetachVolume(v)
+	v, err := client.GetVolumeByID(context.Background(), volID)
+	err = client.DetachVolume(context.Background(), v)
 	if err != nil {
 		fmt.Println("Error encountered while performing iSCSI detach of Volume: ", volID)
 		fmt.Println(err)
@@ -177,7 +247,7 @@ func cmdVolumeAddToVag(c *cli.Context) {
 
 	var volIDs []int64
 	volIDs = append(volIDs, vID)
-	err := client.AddVolumeToAccessGroup(vagID, volIDs)
+	err := client.AddVolumeToAccessGroup(context.Background(), vagID, volIDs)
 	if err != nil {
 		fmt.Printf("Failed to add volume to VAG ID: %d\n", vagID)
 		return
@@ -192,7 +262,7 @@ func cmdVolumeRollback(c *cli.Context) {
 
 	req.VolumeID = vid
 	req.SnapshotID = sid
-	_, err := client.RollbackToSnapshot(&req)
+	_, err := client.RollbackToSnapshot(context.Background(), &req)
 	if err != nil {
 		fmt.Errorf("failed rollback to snapshot: %+v\n", err)
 	}
@@ -208,9 +278,11 @@ func cmdVolumeClone(c *cli.Context) {
 	}
 	req.VolumeID = id
 	req.Name = name
-	v, err := client.CloneVolume(&req)
+	v, err := client.CloneVolumeWithProgress(context.Background(), &req, printProgress)
+	fmt.Println()
 	if err != nil {
 		fmt.Println("Error cloning volume: ", err)
+		return
 	}
 	fmt.Println("-------------------------------------------")
 	fmt.Println("Succesfully Cloned Volume:")
@@ -225,14 +297,17 @@ func cmdVolumeClone(c *cli.Context) {
 
 func cmdVolumeCreate(c *cli.Context) {
 	var req sfapi.CreateVolumeRequest
-	var qos sfapi.QoS
 	req.Name = c.Args().First()
 
 	sz := int64(0)
 	if c.String("size") == "" && client.DefaultVolSize != 0 {
 		sz = client.DefaultVolSize
 	} else if c.String("size") != "" {
-		sz, _ = units.ParseStrictBytes(c.String("size"))
+		var err error
+		if sz, err = parseSize(c.String("size")); err != nil {
+			fmt.Println(err)
+			return
+		}
 	} else {
 		fmt.Println("You must specify size for volumeCreate")
 		return
@@ -242,7 +317,11 @@ func cmdVolumeCreate(c *cli.Context) {
 	if c.String("account") == "" && client.DefaultAccountID != 0 {
 		account = client.DefaultAccountID
 	} else if c.String("account") != "" {
-		account, _ = strconv.ParseInt(c.String("account"), 10, 64)
+		var err error
+		if account, err = resolveAccountID(context.Background(), c.String("account")); err != nil {
+			fmt.Println(err)
+			return
+		}
 	} else {
 		fmt.Println("You must specify an account for volumeCreate")
 		return
@@ -251,21 +330,26 @@ func cmdVolumeCreate(c *cli.Context) {
 	req.TotalSize = sz
 	req.AccountID = account
 	if c.String("qos") != "" {
-		iops := strings.Split(c.String("qos"), ",")
-		qos.MinIOPS, _ = strconv.ParseInt(iops[0], 10, 64)
-		qos.MaxIOPS, _ = strconv.ParseInt(iops[2], 10, 64)
-		qos.BurstIOPS, _ = strconv.ParseInt(iops[2], 10, 64)
-		req.Qos = qos
+		qos, err := parseQoS(c.String("qos"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		req.Qos = &qos
 	} else if c.String("type") != "" {
-		for _, t := range *client.Config.Types {
-			if t.Type == c.String("type") {
-				req.Qos = t.QOS
-			}
+		t, err := lookupVolType(c.String("type"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		req.Qos, req.QoSPolicyID, err = client.VolTypeQoS(context.Background(), t)
+		if err != nil {
+			fmt.Println(err)
+			return
 		}
-	} else {
 	}
 
-	v, err := client.CreateVolume(&req)
+	v, err := client.CreateVolume(context.Background(), &req)
 	if err != nil {
 		fmt.Println("Error creating volume: ", err)
 	}
@@ -283,7 +367,7 @@ func cmdVolumeCreate(c *cli.Context) {
 		vagID, _ := strconv.ParseInt(c.String("vag"), 10, 64)
 		var volIDs []int64
 		volIDs = append(volIDs, v.VolumeID)
-		err := client.AddVolumeToAccessGroup(vagID, volIDs)
+		err := client.AddVolumeToAccessGroup(context.Background(), vagID, volIDs)
 		if err != nil {
 			fmt.Printf("Failed to add volume to VAG ID: %d\n", vagID)
 			return
@@ -293,6 +377,68 @@ func cmdVolumeCreate(c *cli.Context) {
 	return
 }
 
+func cmdVolumeModify(c *cli.Context) {
+	var req sfapi.ModifyVolumeRequest
+	id, err := strconv.ParseInt(c.Args().First(), 10, 64)
+	if err != nil {
+		fmt.Println("Error, missing or invalid VOLUME-ID for modify cmd")
+		return
+	}
+	req.VolumeID = id
+	if c.String("size") != "" {
+		sz, err := parseSize(c.String("size"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		req.TotalSize = sz
+	}
+	if c.String("qos") != "" && c.String("type") != "" {
+		fmt.Println("Only one of --qos and --type may be given")
+		return
+	}
+	if c.String("qos") != "" {
+		qos, err := parseQoS(c.String("qos"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		req.Qos = &qos
+	} else if c.String("type") != "" {
+		t, err := lookupVolType(c.String("type"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		req.Qos, req.QoSPolicyID, err = client.VolTypeQoS(context.Background(), t)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	req.Access = c.String("access")
+	if req.TotalSize == 0 && req.Qos == nil && req.QoSPolicyID == 0 && req.Access == "" {
+		fmt.Println("Nothing to modify, specify at least one of --size, --qos, --type or --access")
+		return
+	}
+
+	v, err := client.ModifyVolume(context.Background(), &req)
+	if err != nil {
+		fmt.Println("Error modifying volume: ", err)
+		return
+	}
+	fmt.Println("-------------------------------------------")
+	fmt.Println("Succesfully Modified Volume:")
+	fmt.Println("-------------------------------------------")
+	fmt.Println("ID:         ", v.VolumeID)
+	fmt.Println("Name:       ", v.Name)
+	fmt.Println("Size (GiB): ", v.TotalSize/int64(units.GiB))
+	fmt.Println("QoS :       ", "minIOPS:", v.Qos.MinIOPS, "maxIOPS:", v.Qos.MaxIOPS, "burstIOPS:", v.Qos.BurstIOPS)
+	fmt.Println("Access:     ", v.Access)
+	fmt.Println("Account:    ", v.AccountID)
+	fmt.Println("-------------------------------------------")
+}
+
 func cmdVolumeDelete(c *cli.Context) {
 	volumes := c.String("range")
 	if volumes != "" {
@@ -302,25 +448,141 @@ func cmdVolumeDelete(c *cli.Context) {
 		if confirm() {
 			startID, _ := strconv.ParseInt(ids[0], 10, 64)
 			endID, _ := strconv.ParseInt(ids[1], 10, 64)
-			client.DeleteRange(startID, endID)
+			client.DeleteRange(context.Background(), startID, endID)
 		}
 
 	} else {
 		for _, arg := range c.Args() {
 			vID, _ := strconv.ParseInt(arg, 10, 64)
-			client.DeleteVolume(vID)
+			client.DeleteVolume(context.Background(), vID)
 		}
 	}
 }
 
+func cmdVolumeDeletedList(c *cli.Context) {
+	volumes, err := client.ListDeletedVolumes(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if c.String("account") != "" {
+		acctID, err := resolveAccountID(context.Background(), c.String("account"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		var filtered []sfapi.Volume
+		for _, v := range volumes {
+			if v.AccountID == acctID {
+				filtered = append(filtered, v)
+			}
+		}
+		volumes = filtered
+	}
+	printDeletedVolList(volumes)
+}
+
+func cmdVolumeDeletedRestore(c *cli.Context) {
+	ctx := context.Background()
+	if len(c.Args()) < 1 {
+		fmt.Println("Missing argument to volume deleted restore, requires VOLUME-ID")
+		return
+	}
+	for _, arg := range c.Args() {
+		vID, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Println("Invalid volume ID: ", arg)
+			return
+		}
+		deleted, err := client.GetDeletedVolumeByID(ctx, vID)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		// Docker finds volumes by name, so restoring one whose name has
+		// since been reused would leave it unable to tell them apart
+		if existing, err := client.GetVolumeByName(ctx, deleted.Name, deleted.AccountID); err == nil {
+			fmt.Printf("Volume %d can't be restored, volume %d in account %d is already named %s\n",
+				vID, existing.VolumeID, deleted.AccountID, deleted.Name)
+			return
+		}
+		v, err := client.RestoreDeletedVolume(ctx, vID)
+		if err != nil {
+			fmt.Println("Error restoring volume: ", err)
+			return
+		}
+		fmt.Println("-------------------------------------------")
+		fmt.Println("Succesfully Restored Volume:")
+		fmt.Println("-------------------------------------------")
+		fmt.Println("ID:         ", v.VolumeID)
+		fmt.Println("Name:       ", v.Name)
+		fmt.Println("Size (GiB): ", v.TotalSize/int64(units.GiB))
+		fmt.Println("Account:    ", v.AccountID)
+		fmt.Println("-------------------------------------------")
+	}
+}
+
+func cmdVolumeDeletedPurge(c *cli.Context) {
+	ctx := context.Background()
+	var ids []int64
+	if c.Bool("all") || c.String("account") != "" {
+		if len(c.Args()) > 0 {
+			fmt.Println("VOLUME-IDs can't be combined with --all or --account")
+			return
+		}
+		deleted, err := client.ListDeletedVolumes(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		var acctID int64
+		if c.String("account") != "" {
+			if acctID, err = resolveAccountID(ctx, c.String("account")); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+		for _, v := range deleted {
+			if acctID == 0 || v.AccountID == acctID {
+				ids = append(ids, v.VolumeID)
+			}
+		}
+	} else {
+		for _, arg := range c.Args() {
+			vID, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				fmt.Println("Invalid volume ID: ", arg)
+				return
+			}
+			ids = append(ids, vID)
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Println("No deleted volumes to purge")
+		return
+	}
+	if !c.Bool("force") {
+		fmt.Println("You've selected to permanently purge volumes: ", ids)
+		fmt.Print("Are you sure you want to do this [yes/no]: ")
+		if !confirm() {
+			return
+		}
+	}
+	if err := client.PurgeDeletedVolumes(ctx, ids); err != nil {
+		fmt.Println("Error purging volumes: ", err)
+		return
+	}
+	fmt.Println("Succesfully purged volumes: ", ids)
+}
+
 func listForAccount(acctID int64) (vols []sfapi.Volume, err error) {
 	var req sfapi.ListVolumesForAccountRequest
 	req.AccountID = acctID
-	return client.ListVolumesForAccount(&req)
+	return client.ListVolumesForAccount(context.Background(), &req)
 }
 
 func listActiveVolumes(req sfapi.ListActiveVolumesRequest) (vols []sfapi.Volume, err error) {
-	return client.ListActiveVolumes(&req)
+	return client.ListActiveVolumes(context.Background(), &req)
 }
 
 func cmdVolumeList(c *cli.Context) {
@@ -329,7 +591,11 @@ func cmdVolumeList(c *cli.Context) {
 	var err error
 
 	if c.String("account") != "" {
-		acctID, _ := strconv.ParseInt(c.String("account"), 10, 64)
+		var acctID int64
+		if acctID, err = resolveAccountID(context.Background(), c.String("account")); err != nil {
+			fmt.Println(err)
+			return
+		}
 		volumes, err = listForAccount(acctID)
 	} else {
 		if c.String("startID") != "" {
@@ -340,7 +606,7 @@ func cmdVolumeList(c *cli.Context) {
 			limit, _ := strconv.ParseInt(c.String("limit"), 10, 64)
 			req.Limit = limit
 		}
-		volumes, err = client.ListActiveVolumes(&req)
+		volumes, err = client.ListActiveVolumes(context.Background(), &req)
 	}
 
 	if err != nil {